@@ -0,0 +1,118 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/apstndb/spannerplanviz/advisor"
+	"github.com/apstndb/spannerplanviz/option"
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+// adviseReport is the JSON output of the advise command for a single plan.
+type adviseReport struct {
+	Description string            `json:"description,omitempty"`
+	Findings    []advisor.Finding `json:"findings"`
+}
+
+// adviseConfig returns the advisor configuration for input, enabling schema-aware rules with --ddl.
+func (input planInput) adviseConfig() advisor.Config {
+	cfg := advisor.DefaultConfig()
+	cfg.Schema = input.schema
+	return cfg
+}
+
+// runAdvise implements the advise subcommand, which prints advisor findings instead of a diagram.
+func runAdvise(ctx context.Context, args []string) error {
+	var opts option.AdviseOptions
+	p := flags.NewParser(&opts, flags.Default|flags.AllowBoolValues)
+	p.Name = "spannerplanviz advise"
+	rest, err := p.ParseArgs(args)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) > 0 {
+		p.WriteHelp(os.Stderr)
+		os.Exit(1)
+	}
+
+	if err := option.ApplyConfig(p, opts.ConfigFile); err != nil {
+		return err
+	}
+	if err := opts.Normalize(); err != nil {
+		return err
+	}
+	minSeverity, err := advisor.ParseSeverity(opts.MinSeverity)
+	if err != nil {
+		return err
+	}
+	rules, err := opts.SelectRules(advisor.DefaultRegistry())
+	if err != nil {
+		return err
+	}
+
+	inputs, err := loadPlans(ctx, p, opts.InputOptions)
+	if err != nil {
+		return err
+	}
+
+	reports := make([]adviseReport, 0, len(inputs))
+	for i, input := range inputs {
+		plan, err := visualize.BuildPlan(input.rowType, input.queryStats, visualize.BuildOptions{})
+		if err != nil {
+			if len(inputs) > 1 {
+				return fmt.Errorf("plan %d: %w", i, err)
+			}
+			return err
+		}
+		findings := advisor.Filter(advisor.AnalyzeWithRules(plan, input.adviseConfig(), rules...), minSeverity)
+		if findings == nil {
+			findings = []advisor.Finding{}
+		}
+		reports = append(reports, adviseReport{Description: input.description, Findings: findings})
+	}
+
+	return writeOutput(opts.Filename, func(w io.Writer) error {
+		if opts.Format == "json" {
+			return writeAdviseJSON(w, reports)
+		}
+		return writeAdviseText(w, reports)
+	})
+}
+
+func writeAdviseJSON(w io.Writer, reports []adviseReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if len(reports) == 1 {
+		return enc.Encode(reports[0].Findings)
+	}
+	return enc.Encode(reports)
+}
+
+func writeAdviseText(w io.Writer, reports []adviseReport) error {
+	for i, report := range reports {
+		if len(reports) > 1 {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "# %s\n", cmp.Or(report.Description, fmt.Sprintf("plan %d", i)))
+		}
+		if len(report.Findings) == 0 {
+			fmt.Fprintln(w, "no findings")
+			continue
+		}
+		for _, f := range report.Findings {
+			if _, err := fmt.Fprintln(w, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Package advisor inspects a built query plan for common anti-patterns such as
+// full table scans, unselective residual filters and spilling operators.
+//
+// Findings refer to plan nodes by index and can be attached to the diagram with
+// Annotate, so that the Graphviz and Mermaid renderers highlight the offending nodes.
+package advisor
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/apstndb/spannerplanviz/schema"
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+// Severity ranks findings.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:     "info",
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Severity) UnmarshalText(b []byte) error {
+	parsed, err := ParseSeverity(string(b))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// Color returns the color used to highlight nodes with findings of this severity.
+func (s Severity) Color() string {
+	switch s {
+	case SeverityCritical:
+		return "red"
+	case SeverityWarning:
+		return "orange"
+	default:
+		return "blue"
+	}
+}
+
+// ParseSeverity parses a severity name case-insensitively.
+func ParseSeverity(s string) (Severity, error) {
+	for severity, name := range severityNames {
+		if strings.EqualFold(s, name) {
+			return severity, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q", s)
+}
+
+// Finding is a single issue reported for a plan node.
+type Finding struct {
+	Rule      string   `json:"rule"`
+	Severity  Severity `json:"severity"`
+	NodeIndex int32    `json:"nodeIndex"`
+	NodeTitle string   `json:"nodeTitle"`
+	Message   string   `json:"message"`
+}
+
+// String formats f as a single line, e.g. "warning node5 (Table Scan): full-table-scan: ...".
+func (f Finding) String() string {
+	return fmt.Sprintf("%s node%d (%s): %s: %s", f.Severity, f.NodeIndex, f.NodeTitle, f.Rule, f.Message)
+}
+
+// Config holds the thresholds used by the rules.
+type Config struct {
+	// MinFilteredRatio is the fraction of scanned rows that a residual condition must discard
+	// to be reported.
+	MinFilteredRatio float64
+	// MaxRemoteCalls is the number of remote calls above which a distributed apply is reported.
+	MaxRemoteCalls float64
+	// MaxHashJoinBuildRows is the number of build side rows above which a hash join is reported.
+	MaxHashJoinBuildRows float64
+	// Schema enables rules that need the database schema, such as index-back-join.
+	// They report nothing if it is nil.
+	Schema *schema.Schema
+}
+
+// DefaultConfig returns the thresholds used by the advise command.
+func DefaultConfig() Config {
+	return Config{
+		MinFilteredRatio:     0.9,
+		MaxRemoteCalls:       1000,
+		MaxHashJoinBuildRows: 100000,
+	}
+}
+
+// Analyze runs the rules of the default registry over plan.
+func Analyze(plan *visualize.Plan, cfg Config) []Finding {
+	return DefaultRegistry().Analyze(plan, cfg)
+}
+
+// AnalyzeWithRules runs rules over plan and returns findings ordered by descending severity,
+// then by node index.
+func AnalyzeWithRules(plan *visualize.Plan, cfg Config, rules ...Rule) []Finding {
+	if plan == nil || plan.Root == nil {
+		return nil
+	}
+
+	ctx := newContext(plan, cfg)
+	var findings []Finding
+	plan.Walk(func(node *visualize.TreeNode) bool {
+		if node.OmittedOperators() > 0 {
+			return true
+		}
+		for _, r := range rules {
+			for _, f := range r.Check(node, ctx) {
+				if f.Rule == "" {
+					f.Rule = r.Name()
+				}
+				if f.NodeTitle == "" {
+					f.NodeIndex = node.PlanNode().GetIndex()
+					f.NodeTitle = node.GetTitle()
+				}
+				findings = append(findings, f)
+			}
+		}
+		return true
+	})
+
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		return cmp.Or(cmp.Compare(b.Severity, a.Severity), cmp.Compare(a.NodeIndex, b.NodeIndex))
+	})
+	return findings
+}
+
+// Filter returns the findings with at least the given severity.
+func Filter(findings []Finding, minSeverity Severity) []Finding {
+	return slices.DeleteFunc(slices.Clone(findings), func(f Finding) bool {
+		return f.Severity < minSeverity
+	})
+}
+
+// Annotate attaches findings to the matching nodes of plan so that renderers highlight them.
+// Findings should be ordered by descending severity, as returned by Analyze,
+// because the first annotation of a node determines its outline color.
+func Annotate(plan *visualize.Plan, findings []Finding) {
+	if plan == nil || plan.Root == nil {
+		return
+	}
+
+	nodes := make(map[int32]*visualize.TreeNode)
+	plan.Walk(func(node *visualize.TreeNode) bool {
+		if node.OmittedOperators() == 0 {
+			nodes[node.PlanNode().GetIndex()] = node
+		}
+		return true
+	})
+
+	for _, f := range findings {
+		node, ok := nodes[f.NodeIndex]
+		if !ok {
+			continue
+		}
+		node.AddAnnotation(visualize.Annotation{
+			Text:  fmt.Sprintf("%s: %s", strings.ToUpper(f.Severity.String()), f.Message),
+			Color: f.Severity.Color(),
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package advisor_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/apstndb/spannerplanviz/advisor"
+	"github.com/apstndb/spannerplanviz/schema"
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+func loadPlan(t *testing.T, name string) *visualize.Plan {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join("..", "visualize", "testdata", name))
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	var rs sppb.ResultSet
+	if err := protojson.Unmarshal(b, &rs); err != nil {
+		t.Fatalf("unmarshal %s: %v", name, err)
+	}
+	plan, err := visualize.BuildPlan(rs.GetMetadata().GetRowType(), rs.GetStats(), visualize.BuildOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+	return plan
+}
+
+func stats(t *testing.T, totals map[string]string) *structpb.Struct {
+	t.Helper()
+
+	m := make(map[string]any, len(totals))
+	for k, v := range totals {
+		m[k] = map[string]any{"total": v}
+	}
+	s, err := structpb.NewStruct(m)
+	if err != nil {
+		t.Fatalf("NewStruct() error = %v", err)
+	}
+	return s
+}
+
+func buildPlan(t *testing.T, nodes []*sppb.PlanNode) *visualize.Plan {
+	t.Helper()
+
+	plan, err := visualize.BuildPlan(nil, &sppb.ResultSetStats{QueryPlan: &sppb.QueryPlan{PlanNodes: nodes}}, visualize.BuildOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+	return plan
+}
+
+func findNode(node *visualize.TreeNode, index int32) *visualize.TreeNode {
+	if node.PlanNode().GetIndex() == index {
+		return node
+	}
+	for _, child := range node.Children {
+		if found := findNode(child.ChildNode, index); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestAnalyze_dcaProfile(t *testing.T) {
+	t.Parallel()
+
+	findings := advisor.Analyze(loadPlan(t, "dca_profile.json"), advisor.DefaultConfig())
+
+	want := []advisor.Finding{
+		{
+			Rule:      "full-table-scan",
+			Severity:  advisor.SeverityWarning,
+			NodeIndex: 5,
+			NodeTitle: "Table Scan",
+			Message:   "Table Scan scans Singers without a seek condition (1000 rows scanned)",
+		},
+		{
+			Rule:      "unselective-residual-filter",
+			Severity:  advisor.SeverityWarning,
+			NodeIndex: 28,
+			NodeTitle: "Filter Scan",
+			Message:   "residual condition discards 99.7% of 1024000 scanned rows; consider an index that covers the condition",
+		},
+	}
+	if diff := cmp.Diff(want, findings); diff != "" {
+		t.Errorf("Analyze() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAnalyze_indexBackJoin(t *testing.T) {
+	t.Parallel()
+
+	sch, err := schema.Parse("schema.sql", `
+CREATE TABLE Singers (SingerId INT64 NOT NULL, FirstName STRING(1024), LastName STRING(1024)) PRIMARY KEY (SingerId);
+CREATE TABLE Albums (SingerId INT64 NOT NULL, AlbumId INT64 NOT NULL, AlbumTitle STRING(MAX)) PRIMARY KEY (SingerId, AlbumId), INTERLEAVE IN PARENT Singers;
+CREATE INDEX SingersByFirstLastName ON Singers(FirstName, LastName);
+`)
+	if err != nil {
+		t.Fatalf("schema.Parse() error = %v", err)
+	}
+	rule, ok := advisor.DefaultRegistry().Lookup("index-back-join")
+	if !ok {
+		t.Fatal("index-back-join is not registered")
+	}
+	rules := []advisor.Rule{rule}
+	plan := loadPlan(t, "various_characters_profile.json")
+
+	cfg := advisor.DefaultConfig()
+	cfg.Schema = sch
+	want := []advisor.Finding{{
+		Rule:      "index-back-join",
+		Severity:  advisor.SeverityWarning,
+		NodeIndex: 6,
+		NodeTitle: "Index Scan",
+		Message:   "index SingersByFirstLastName is joined back to its base table Singers (node26) by Distributed Cross Apply node1; consider adding the columns read from Singers to STORING",
+	}}
+	if diff := cmp.Diff(want, advisor.AnalyzeWithRules(plan, cfg, rules...)); diff != "" {
+		t.Errorf("AnalyzeWithRules() mismatch (-want +got):\n%s", diff)
+	}
+
+	if findings := advisor.AnalyzeWithRules(plan, advisor.DefaultConfig(), rules...); len(findings) != 0 {
+		t.Errorf("AnalyzeWithRules() without schema = %v, want no findings", findings)
+	}
+}
+
+func TestAnalyze_rules(t *testing.T) {
+	t.Parallel()
+
+	scanMetadata := func(scanType string) *structpb.Struct {
+		s, _ := structpb.NewStruct(map[string]any{"scan_type": scanType, "scan_target": "T"})
+		return s
+	}
+
+	tests := []struct {
+		name  string
+		nodes []*sppb.PlanNode
+		want  []string
+	}{
+		{
+			name: "remote calls",
+			nodes: []*sppb.PlanNode{
+				{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Distributed Cross Apply", ExecutionStats: stats(t, map[string]string{"remote_calls": "5000"})},
+			},
+			want: []string{"warning node0 (Distributed Cross Apply): remote-calls: 5000 remote calls; the input side may not be co-located with the map side"},
+		},
+		{
+			name: "few remote calls",
+			nodes: []*sppb.PlanNode{
+				{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Distributed Cross Apply", ExecutionStats: stats(t, map[string]string{"remote_calls": "10"})},
+			},
+		},
+		{
+			name: "hash join build side",
+			nodes: []*sppb.PlanNode{
+				{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Hash Join", ChildLinks: []*sppb.PlanNode_ChildLink{
+					{ChildIndex: 1, Type: "Build"},
+					{ChildIndex: 2, Type: "Probe"},
+				}},
+				{Index: 1, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Union All", ExecutionStats: stats(t, map[string]string{"rows": "200000"})},
+				{Index: 2, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Union All", ExecutionStats: stats(t, map[string]string{"rows": "10"})},
+			},
+			want: []string{"warning node0 (Hash Join): hash-join-build-side: build side has 200000 rows; consider building on the smaller input"},
+		},
+		{
+			name: "spill",
+			nodes: []*sppb.PlanNode{
+				{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Sort", ExecutionStats: stats(t, map[string]string{"Disk Usage (KBytes)": "512", "Rows Spooled": "0"})},
+			},
+			want: []string{"critical node0 (Sort): spill-to-disk: spills to disk: 512 KBytes of disk"},
+		},
+		{
+			name: "seek condition on Filter Scan",
+			nodes: []*sppb.PlanNode{
+				{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Filter Scan", ChildLinks: []*sppb.PlanNode_ChildLink{
+					{ChildIndex: 1},
+					{ChildIndex: 2, Type: "Seek Condition"},
+				}},
+				{Index: 1, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Scan", Metadata: scanMetadata("IndexScan")},
+				{Index: 2, Kind: sppb.PlanNode_SCALAR, DisplayName: "Function"},
+			},
+		},
+		{
+			name: "index scan without seek condition",
+			nodes: []*sppb.PlanNode{
+				{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Scan", Metadata: scanMetadata("IndexScan")},
+			},
+			want: []string{"warning node0 (Index Scan): full-table-scan: Index Scan scans T without a seek condition"},
+		},
+		{
+			name: "batch scan",
+			nodes: []*sppb.PlanNode{
+				{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Scan", Metadata: scanMetadata("BatchScan")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			for _, f := range advisor.Analyze(buildPlan(t, tt.nodes), advisor.DefaultConfig()) {
+				got = append(got, f.String())
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Analyze() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	t.Parallel()
+
+	plan := loadPlan(t, "dca_profile.json")
+	findings := []advisor.Finding{
+		{Rule: "a", Severity: advisor.SeverityCritical, NodeIndex: 5, Message: "first"},
+		{Rule: "b", Severity: advisor.SeverityInfo, NodeIndex: 5, Message: "second"},
+		{Rule: "c", Severity: advisor.SeverityInfo, NodeIndex: 999, Message: "unknown node"},
+	}
+	advisor.Annotate(plan, findings)
+
+	node := findNode(plan.Root, 5)
+	if node == nil {
+		t.Fatal("node5 not found")
+	}
+	want := []visualize.Annotation{
+		{Text: "CRITICAL: first", Color: "red"},
+		{Text: "INFO: second", Color: "blue"},
+	}
+	if diff := cmp.Diff(want, node.Annotations); diff != "" {
+		t.Errorf("Annotations mismatch (-want +got):\n%s", diff)
+	}
+	if got := node.OutlineColor(); got != "red" {
+		t.Errorf("OutlineColor() = %q, want red", got)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	findings := []advisor.Finding{
+		{Rule: "a", Severity: advisor.SeverityCritical},
+		{Rule: "b", Severity: advisor.SeverityInfo},
+	}
+	got := advisor.Filter(findings, advisor.SeverityWarning)
+	if len(got) != 1 || got[0].Rule != "a" {
+		t.Errorf("Filter() = %v, want only rule a", got)
+	}
+	if len(findings) != 2 {
+		t.Error("Filter() modified its input")
+	}
+}
+
+func TestSeverity_text(t *testing.T) {
+	t.Parallel()
+
+	b, err := json.Marshal(advisor.Finding{Severity: advisor.SeverityWarning})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(b), `"severity":"warning"`) {
+		t.Errorf("Marshal() = %s, want severity as text", b)
+	}
+
+	if s, err := advisor.ParseSeverity("CRITICAL"); err != nil || s != advisor.SeverityCritical {
+		t.Errorf("ParseSeverity(CRITICAL) = %v, %v", s, err)
+	}
+	if _, err := advisor.ParseSeverity("fatal"); err == nil {
+		t.Error("ParseSeverity(fatal) error = nil")
+	}
+}
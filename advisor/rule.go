@@ -0,0 +1,177 @@
+package advisor
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan"
+	"github.com/apstndb/spannerplan/plantree"
+
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+// Rule is a plan check. Check is called once for every operator of the plan.
+//
+// Findings returned by Check default to the rule name and to the checked node:
+// Rule is set to Name() when empty, and NodeIndex and NodeTitle are set from node
+// when NodeTitle is empty. A rule may report another node by setting both.
+type Rule interface {
+	Name() string
+	Check(node *visualize.TreeNode, ctx *Context) []Finding
+}
+
+// NewRule returns a Rule that calls check.
+func NewRule(name string, check func(node *visualize.TreeNode, ctx *Context) []Finding) Rule {
+	return &funcRule{name: name, check: check}
+}
+
+type funcRule struct {
+	name  string
+	check func(*visualize.TreeNode, *Context) []Finding
+}
+
+func (r *funcRule) Name() string { return r.name }
+
+func (r *funcRule) Check(node *visualize.TreeNode, ctx *Context) []Finding {
+	return r.check(node, ctx)
+}
+
+// Context gives rules access to the plan being analyzed. The node itself provides its
+// parent with TreeNode.Parent, its parsed execution stats with TreeNode.ExecutionStat,
+// and its metadata, including keys such as scan_type that are not displayed, with TreeNode.PlanNode.
+type Context struct {
+	Plan   *visualize.Plan
+	Config Config
+
+	rowsOnce sync.Once
+	rows     map[int32]plantree.RowWithPredicates
+	rowsErr  error
+}
+
+func newContext(plan *visualize.Plan, cfg Config) *Context {
+	return &Context{Plan: plan, Config: cfg}
+}
+
+// ChildLinkTypes returns the types of all child links of node, including scalar ones
+// such as "Seek Condition" and "Residual Condition". Untyped links are omitted.
+func (c *Context) ChildLinkTypes(node *visualize.TreeNode) []string {
+	var types []string
+	for _, cl := range node.PlanNode().GetChildLinks() {
+		if t := c.Plan.QueryPlan.GetLinkType(cl); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// HasChildLinkType reports whether node has a child link of the given type.
+func (c *Context) HasChildLinkType(node *visualize.TreeNode, linkType string) bool {
+	return slices.Contains(c.ChildLinkTypes(node), linkType)
+}
+
+// ChildByLinkType returns the first child plan node linked with the given type, or nil.
+func (c *Context) ChildByLinkType(node *visualize.TreeNode, linkType string) *sppb.PlanNode {
+	for _, cl := range node.PlanNode().GetChildLinks() {
+		if c.Plan.QueryPlan.GetLinkType(cl) == linkType {
+			return c.Plan.QueryPlan.GetNodeByChildLink(cl)
+		}
+	}
+	return nil
+}
+
+// ScalarChildLinks returns the scalar child links of node, such as conditions and
+// variable assignments, with their expressions rendered as text.
+// They are available regardless of the BuildOptions of the plan.
+func (c *Context) ScalarChildLinks(node *visualize.TreeNode) ([]plantree.ScalarChildLink, error) {
+	if row := node.PlanRow(); row != nil {
+		return row.ScalarChildLinks, nil
+	}
+
+	c.rowsOnce.Do(func() {
+		rows, err := plantree.ProcessPlan(c.Plan.QueryPlan, plantree.WithQueryPlanOptions(spannerplan.HideMetadata()))
+		if err != nil {
+			c.rowsErr = err
+			return
+		}
+		c.rows = make(map[int32]plantree.RowWithPredicates, len(rows))
+		for _, row := range rows {
+			c.rows[row.ID] = row
+		}
+	})
+	if c.rowsErr != nil {
+		return nil, c.rowsErr
+	}
+	return c.rows[node.PlanNode().GetIndex()].ScalarChildLinks, nil
+}
+
+// Registry is an ordered set of rules with unique names. It is safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+// NewRegistry returns a registry containing rules.
+// It panics if two rules have the same name.
+func NewRegistry(rules ...Rule) *Registry {
+	r := &Registry{}
+	for _, rule := range rules {
+		if err := r.Register(rule); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register adds rule to the registry.
+func (r *Registry) Register(rule Rule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if slices.ContainsFunc(r.rules, func(existing Rule) bool { return existing.Name() == rule.Name() }) {
+		return fmt.Errorf("advisor: rule %q is already registered", rule.Name())
+	}
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+// Lookup returns the rule with the given name.
+func (r *Registry) Lookup(name string) (Rule, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := slices.IndexFunc(r.rules, func(rule Rule) bool { return rule.Name() == name })
+	if i < 0 {
+		return nil, false
+	}
+	return r.rules[i], true
+}
+
+// Rules returns the registered rules in registration order.
+func (r *Registry) Rules() []Rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.rules)
+}
+
+// Analyze runs the registered rules over plan.
+func (r *Registry) Analyze(plan *visualize.Plan, cfg Config) []Finding {
+	return AnalyzeWithRules(plan, cfg, r.Rules()...)
+}
+
+var defaultRegistry = NewRegistry(BuiltinRules()...)
+
+// DefaultRegistry returns the registry used by Analyze and the advise command.
+// It initially contains BuiltinRules.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register adds rule to the default registry. It is intended to be called from init functions
+// and panics if a rule with the same name is already registered.
+func Register(rule Rule) {
+	if err := defaultRegistry.Register(rule); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,111 @@
+package advisor_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/apstndb/spannerplanviz/advisor"
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+// noScanWithoutIndex is an organisation-specific rule: table Songs must only be read through SongsBySongName.
+var noScanWithoutIndex = advisor.NewRule("songs-require-index", func(node *visualize.TreeNode, ctx *advisor.Context) []advisor.Finding {
+	metadata := node.PlanNode().GetMetadata().GetFields()
+	if metadata["scan_type"].GetStringValue() != "TableScan" || metadata["scan_target"].GetStringValue() != "Songs" {
+		return nil
+	}
+	return []advisor.Finding{{Severity: advisor.SeverityCritical, Message: "Songs must be read through SongsBySongName"}}
+})
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := advisor.NewRegistry(advisor.BuiltinRules()...)
+	if err := registry.Register(noScanWithoutIndex); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := registry.Register(noScanWithoutIndex); err == nil {
+		t.Error("Register() of a duplicate rule error = nil")
+	}
+	if _, ok := registry.Lookup("songs-require-index"); !ok {
+		t.Error("Lookup() did not find the registered rule")
+	}
+
+	var names []string
+	for _, rule := range registry.Rules() {
+		names = append(names, rule.Name())
+	}
+	wantNames := []string{"full-table-scan", "unselective-residual-filter", "remote-calls", "hash-join-build-side", "spill-to-disk", "index-back-join", "songs-require-index"}
+	if diff := cmp.Diff(wantNames, names); diff != "" {
+		t.Errorf("Rules() mismatch (-want +got):\n%s", diff)
+	}
+
+	findings := registry.Analyze(loadPlan(t, "dca_profile.json"), advisor.DefaultConfig())
+	want := advisor.Finding{
+		Rule:      "songs-require-index",
+		Severity:  advisor.SeverityCritical,
+		NodeIndex: 29,
+		NodeTitle: "Table Scan",
+		Message:   "Songs must be read through SongsBySongName",
+	}
+	if len(findings) == 0 || findings[0] != want {
+		t.Errorf("Analyze() = %v, want %v first", findings, want)
+	}
+}
+
+func TestContext(t *testing.T) {
+	t.Parallel()
+
+	plan := loadPlan(t, "dca_profile.json")
+	var got []string
+	probe := advisor.NewRule("probe", func(node *visualize.TreeNode, ctx *advisor.Context) []advisor.Finding {
+		switch node.PlanNode().GetIndex() {
+		case 29:
+			links, err := ctx.ScalarChildLinks(node)
+			if err != nil {
+				t.Errorf("ScalarChildLinks() error = %v", err)
+			}
+			for _, link := range links {
+				if link.Type == "Seek Condition" {
+					got = append(got, "seek: "+link.Description)
+				}
+			}
+			got = append(got, "parent: "+node.Parent().GetTitle())
+			got = append(got, "links: "+strings.Join(ctx.ChildLinkTypes(node), ","))
+			if rows, ok := node.ExecutionStat("rows"); ok {
+				got = append(got, fmt.Sprintf("rows: %v", rows.Total))
+			}
+		case 0:
+			if node.Parent() != nil {
+				t.Error("Parent(root) != nil")
+			}
+		}
+		return nil
+	})
+
+	if findings := advisor.AnalyzeWithRules(plan, advisor.DefaultConfig(), probe); len(findings) != 0 {
+		t.Errorf("AnalyzeWithRules() = %v, want no findings", findings)
+	}
+	want := []string{
+		"seek: ($SingerId_1 = $batched_SingerId)",
+		"parent: Filter Scan",
+		"links: Seek Condition",
+		"rows: 3069",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("context mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	t.Parallel()
+
+	rules := advisor.DefaultRegistry().Rules()
+	if !slices.ContainsFunc(rules, func(r advisor.Rule) bool { return r.Name() == "full-table-scan" }) {
+		t.Error("DefaultRegistry() does not contain the builtin rules")
+	}
+}
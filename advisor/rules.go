@@ -0,0 +1,210 @@
+package advisor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan"
+
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+// BuiltinRules returns the rules shipped with this package:
+//   - full-table-scan: table and index scans without a seek condition
+//   - unselective-residual-filter: Filter Scans whose residual condition discards most scanned rows
+//   - remote-calls: distributed applies with many remote calls
+//   - hash-join-build-side: hash joins with a large build side
+//   - spill-to-disk: operators that use disk or spool rows
+//   - index-back-join: index scans joined back to their base table, which needs Config.Schema
+func BuiltinRules() []Rule {
+	return []Rule{
+		NewRule("full-table-scan", checkFullTableScan),
+		NewRule("unselective-residual-filter", checkResidualFilter),
+		NewRule("remote-calls", checkRemoteCalls),
+		NewRule("hash-join-build-side", checkHashJoinBuildSide),
+		NewRule("spill-to-disk", checkSpill),
+		NewRule("index-back-join", checkIndexBackJoin),
+	}
+}
+
+func checkFullTableScan(node *visualize.TreeNode, ctx *Context) []Finding {
+	typ := scanType(node.PlanNode())
+	if typ != "TableScan" && typ != "IndexScan" {
+		return nil
+	}
+	if ctx.HasChildLinkType(node, "Seek Condition") {
+		return nil
+	}
+	// Older plans attach the seek condition to the enclosing Filter Scan.
+	if parent := node.Parent(); parent != nil && parent.PlanNode().GetDisplayName() == "Filter Scan" &&
+		ctx.HasChildLinkType(parent, "Seek Condition") {
+		return nil
+	}
+
+	message := fmt.Sprintf("%s Scan scans %s without a seek condition", strings.TrimSuffix(typ, "Scan"), scanTarget(node.PlanNode()))
+	if scanned, ok := node.ExecutionStat("scanned_rows"); ok {
+		message += fmt.Sprintf(" (%s rows scanned)", formatNumber(scanned.Total))
+	}
+	return []Finding{{Severity: SeverityWarning, Message: message}}
+}
+
+func checkResidualFilter(node *visualize.TreeNode, ctx *Context) []Finding {
+	if node.PlanNode().GetDisplayName() != "Filter Scan" || !ctx.HasChildLinkType(node, "Residual Condition") {
+		return nil
+	}
+
+	// Execution stats are usually reported on the Scan below the Filter Scan.
+	statsNode := node
+	if _, ok := statsNode.ExecutionStat("scanned_rows"); !ok {
+		statsNode = scanChild(node)
+		if statsNode == nil {
+			return nil
+		}
+	}
+	scanned, ok := statsNode.ExecutionStat("scanned_rows")
+	if !ok || scanned.Total == 0 {
+		return nil
+	}
+	rows, ok := statsNode.ExecutionStat("rows")
+	if !ok {
+		return nil
+	}
+
+	discarded := 1 - rows.Total/scanned.Total
+	if discarded < ctx.Config.MinFilteredRatio {
+		return nil
+	}
+	return []Finding{{
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf("residual condition discards %.1f%% of %s scanned rows; consider an index that covers the condition", discarded*100, formatNumber(scanned.Total)),
+	}}
+}
+
+func checkRemoteCalls(node *visualize.TreeNode, ctx *Context) []Finding {
+	name := node.PlanNode().GetDisplayName()
+	if !strings.HasPrefix(name, "Distributed") || !strings.HasSuffix(name, "Apply") {
+		return nil
+	}
+	calls, ok := node.ExecutionStat("remote_calls")
+	if !ok || calls.Total <= ctx.Config.MaxRemoteCalls {
+		return nil
+	}
+	return []Finding{{
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf("%s remote calls; the input side may not be co-located with the map side", formatNumber(calls.Total)),
+	}}
+}
+
+func checkHashJoinBuildSide(node *visualize.TreeNode, ctx *Context) []Finding {
+	if node.PlanNode().GetDisplayName() != "Hash Join" {
+		return nil
+	}
+	build := childByLinkType(node, "Build")
+	if build == nil {
+		return nil
+	}
+	rows, ok := build.ExecutionStat("rows")
+	if !ok || rows.Total <= ctx.Config.MaxHashJoinBuildRows {
+		return nil
+	}
+	return []Finding{{
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf("build side has %s rows; consider building on the smaller input", formatNumber(rows.Total)),
+	}}
+}
+
+func checkSpill(node *visualize.TreeNode, ctx *Context) []Finding {
+	var parts []string
+	// Sizes are parsed into bytes.
+	if disk, ok := node.ExecutionStat("Disk Usage (KBytes)"); ok && disk.Total > 0 {
+		parts = append(parts, fmt.Sprintf("%s KBytes of disk", formatNumber(disk.Total/1024)))
+	}
+	if rows, ok := node.ExecutionStat("Rows Spooled"); ok && rows.Total > 0 {
+		parts = append(parts, fmt.Sprintf("%s rows spooled", formatNumber(rows.Total)))
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return []Finding{{Severity: SeverityCritical, Message: "spills to disk: " + strings.Join(parts, ", ")}}
+}
+
+// checkIndexBackJoin reports index scans on the input side of an apply whose map side
+// scans the base table of the index, which usually means that the index does not store
+// all columns the query reads. The finding is reported for the index scan.
+func checkIndexBackJoin(node *visualize.TreeNode, ctx *Context) []Finding {
+	if ctx.Config.Schema == nil || !strings.HasSuffix(node.PlanNode().GetDisplayName(), "Apply") {
+		return nil
+	}
+	input, mapSide := ctx.ChildByLinkType(node, "Input"), ctx.ChildByLinkType(node, "Map")
+	if input == nil || mapSide == nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, indexScan := range ctx.scans(input, "IndexScan") {
+		index := ctx.Config.Schema.Index(scanTarget(indexScan))
+		if index == nil {
+			continue
+		}
+		for _, tableScan := range ctx.scans(mapSide, "TableScan") {
+			if !strings.EqualFold(scanTarget(tableScan), index.Table) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Severity:  SeverityWarning,
+				NodeIndex: indexScan.GetIndex(),
+				NodeTitle: spannerplan.NodeTitle(indexScan, spannerplan.HideMetadata()),
+				Message: fmt.Sprintf("index %s is joined back to its base table %s (node%d) by %s node%d; consider adding the columns read from %s to STORING",
+					index.Name, index.Table, tableScan.GetIndex(), node.GetTitle(), node.PlanNode().GetIndex(), index.Table),
+			})
+			break
+		}
+	}
+	return findings
+}
+
+// scans returns the scan operators of type typ in the operator tree rooted at node.
+func (c *Context) scans(node *sppb.PlanNode, typ string) []*sppb.PlanNode {
+	var result []*sppb.PlanNode
+	if scanType(node) == typ {
+		result = append(result, node)
+	}
+	for _, cl := range c.Plan.QueryPlan.VisibleChildLinks(node) {
+		result = append(result, c.scans(c.Plan.QueryPlan.GetNodeByChildLink(cl), typ)...)
+	}
+	return result
+}
+
+func scanType(node *sppb.PlanNode) string {
+	return node.GetMetadata().GetFields()["scan_type"].GetStringValue()
+}
+
+func scanTarget(node *sppb.PlanNode) string {
+	return node.GetMetadata().GetFields()["scan_target"].GetStringValue()
+}
+
+// scanChild returns the first Scan child of node, or nil.
+func scanChild(node *visualize.TreeNode) *visualize.TreeNode {
+	for _, child := range node.PlanChildren() {
+		if child.ChildNode.PlanNode().GetDisplayName() == "Scan" {
+			return child.ChildNode
+		}
+	}
+	return nil
+}
+
+// childByLinkType returns the child operator of node linked with linkType, or nil.
+func childByLinkType(node *visualize.TreeNode, linkType string) *visualize.TreeNode {
+	for _, child := range node.PlanChildren() {
+		if child.ChildType == linkType {
+			return child.ChildNode
+		}
+	}
+	return nil
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/apstndb/spannerplan"
+
+	"github.com/apstndb/spannerplanviz/mermaid"
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+// parseBuildOptions decodes BuildOptions from JSON.
+// Field names match visualize.BuildOptions case-insensitively (e.g. "executionStats").
+// An empty string selects visualize.StructureBuildOptions, which is the recommended preset for browsers.
+func parseBuildOptions(optionsJSON string) (visualize.BuildOptions, error) {
+	if strings.TrimSpace(optionsJSON) == "" || optionsJSON == "null" {
+		return visualize.StructureBuildOptions(), nil
+	}
+
+	var opts visualize.BuildOptions
+	dec := json.NewDecoder(strings.NewReader(optionsJSON))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&opts); err != nil {
+		return visualize.BuildOptions{}, fmt.Errorf("invalid options: %w", err)
+	}
+	return opts, nil
+}
+
+func buildPlan(planJSON, optionsJSON string) (*visualize.Plan, error) {
+	opts, err := parseBuildOptions(optionsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	queryStats, rowType, err := spannerplan.ExtractQueryPlan([]byte(planJSON))
+	if err != nil {
+		return nil, err
+	}
+	return visualize.BuildPlan(rowType, queryStats, opts)
+}
+
+// renderMermaid returns Mermaid.js source for planJSON.
+func renderMermaid(planJSON, optionsJSON string) (string, error) {
+	plan, err := buildPlan(planJSON, optionsJSON)
+	if err != nil {
+		return "", err
+	}
+	return mermaid.Source(plan)
+}
+
+// jsonNode is the JSON representation of visualize.TreeNode returned by buildPlanJSON.
+type jsonNode struct {
+	ID                  string            `json:"id"`
+	Title               string            `json:"title,omitempty"`
+	ShortRepresentation string            `json:"shortRepresentation,omitempty"`
+	ScanInfo            string            `json:"scanInfo,omitempty"`
+	Metadata            map[string]string `json:"metadata,omitempty"`
+	Stats               map[string]string `json:"stats,omitempty"`
+	ExecutionSummary    string            `json:"executionSummary,omitempty"`
+	MermaidLabel        string            `json:"mermaidLabel"`
+	Highlighted         bool              `json:"highlighted,omitempty"`
+	Children            []jsonLink        `json:"children,omitempty"`
+	Expressions         []jsonLink        `json:"expressions,omitempty"`
+}
+
+type jsonLink struct {
+	Type  string    `json:"type,omitempty"`
+	Style string    `json:"style"`
+	Child *jsonNode `json:"child"`
+}
+
+// jsonVariableFlow is the JSON representation of visualize.VariableFlow, with node IDs.
+type jsonVariableFlow struct {
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Variables []string `json:"variables"`
+}
+
+type jsonPlan struct {
+	Root          *jsonNode              `json:"root"`
+	VariableFlows []jsonVariableFlow     `json:"variableFlows,omitempty"`
+	Options       visualize.BuildOptions `json:"options"`
+}
+
+func edgeStyleName(style visualize.EdgeStyle) string {
+	switch style {
+	case visualize.EdgeStyleDashed:
+		return "dashed"
+	case visualize.EdgeStyleDotted:
+		return "dotted"
+	default:
+		return "solid"
+	}
+}
+
+func toJSONNode(plan *visualize.Plan, node *visualize.TreeNode) *jsonNode {
+	n := &jsonNode{
+		ID:                  node.GetName(),
+		Title:               node.GetTitle(),
+		ShortRepresentation: node.GetShortRepresentation(),
+		ScanInfo:            node.GetScanInfoOutput(plan.Build),
+		Stats:               node.GetStats(plan.Build),
+		ExecutionSummary:    node.GetExecutionSummary(plan.Build),
+		MermaidLabel:        node.MermaidLabel(plan.Build, plan.RowType),
+		Highlighted:         node.IsHighlighted(),
+	}
+	if plan.Build.Metadata {
+		n.Metadata = node.GetMetadata(plan.Build)
+	}
+	n.Children = toJSONLinks(plan, node.Children)
+	n.Expressions = toJSONLinks(plan, node.ExpressionLinks())
+	return n
+}
+
+func toJSONLinks(plan *visualize.Plan, links []*visualize.Link) []jsonLink {
+	var result []jsonLink
+	for _, link := range links {
+		result = append(result, jsonLink{
+			Type:  link.ChildType,
+			Style: edgeStyleName(link.Style),
+			Child: toJSONNode(plan, link.ChildNode),
+		})
+	}
+	return result
+}
+
+// buildPlanJSON returns the built diagram model for planJSON as JSON.
+func buildPlanJSON(planJSON, optionsJSON string) (string, error) {
+	plan, err := buildPlan(planJSON, optionsJSON)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	result := jsonPlan{Root: toJSONNode(plan, plan.Root), Options: plan.Build}
+	for _, flow := range plan.VariableFlows {
+		result.VariableFlows = append(result.VariableFlows, jsonVariableFlow{
+			From:      flow.From.GetName(),
+			To:        flow.To.GetName(),
+			Variables: flow.Variables,
+		})
+	}
+	if err := enc.Encode(result); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testdataPath(name string) string {
+	return filepath.Join("..", "..", "visualize", "testdata", name)
+}
+
+func readPlan(t *testing.T) string {
+	t.Helper()
+
+	b, err := os.ReadFile(testdataPath("dca_profile.json"))
+	if err != nil {
+		t.Fatalf("read dca_profile.json: %v", err)
+	}
+	return string(b)
+}
+
+func TestParseBuildOptions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty uses structure preset", func(t *testing.T) {
+		opts, err := parseBuildOptions("")
+		if err != nil {
+			t.Fatalf("parseBuildOptions() error = %v", err)
+		}
+		if !opts.Metadata || !opts.SerializeResult || opts.ExecutionStats {
+			t.Fatalf("parseBuildOptions(\"\") = %+v, want StructureBuildOptions", opts)
+		}
+	})
+
+	t.Run("field names are case-insensitive", func(t *testing.T) {
+		opts, err := parseBuildOptions(`{"executionStats": true, "HideMetadata": ["Full scan"]}`)
+		if err != nil {
+			t.Fatalf("parseBuildOptions() error = %v", err)
+		}
+		if !opts.ExecutionStats || len(opts.HideMetadata) != 1 {
+			t.Fatalf("parseBuildOptions() = %+v", opts)
+		}
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		if _, err := parseBuildOptions(`{"unknown": true}`); err == nil {
+			t.Fatal("parseBuildOptions() error = nil, want unknown field error")
+		}
+	})
+}
+
+func TestRenderMermaid(t *testing.T) {
+	t.Parallel()
+
+	src, err := renderMermaid(readPlan(t), `{"full": true}`)
+	if err != nil {
+		t.Fatalf("renderMermaid() error = %v", err)
+	}
+
+	golden, err := os.ReadFile(testdataPath("dca_profile.golden.mermaid"))
+	if err != nil {
+		t.Fatalf("read golden: %v", err)
+	}
+	if strings.TrimSpace(src) != strings.TrimSpace(string(golden)) {
+		t.Fatal("renderMermaid() output differs from dca_profile.golden.mermaid")
+	}
+}
+
+func TestBuildPlanJSON(t *testing.T) {
+	t.Parallel()
+
+	out, err := buildPlanJSON(readPlan(t), `{"executionStats": true}`)
+	if err != nil {
+		t.Fatalf("buildPlanJSON() error = %v", err)
+	}
+
+	var got jsonPlan
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("unmarshal buildPlanJSON() output: %v", err)
+	}
+	if got.Root == nil || got.Root.ID != "node0" {
+		t.Fatalf("root = %+v, want node0", got.Root)
+	}
+	if got.Root.Stats["latency"] == "" {
+		t.Fatalf("root stats = %v, want latency", got.Root.Stats)
+	}
+	if len(got.Root.Children) == 0 || got.Root.Children[0].Child == nil {
+		t.Fatalf("root has no children")
+	}
+}
+
+func TestBuildPlanJSON_scalarExpressions(t *testing.T) {
+	t.Parallel()
+
+	out, err := buildPlanJSON(readPlan(t), `{"scalarExpressions": true}`)
+	if err != nil {
+		t.Fatalf("buildPlanJSON() error = %v", err)
+	}
+
+	var got jsonPlan
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("unmarshal buildPlanJSON() output: %v", err)
+	}
+	if len(got.Root.Expressions) != 1 {
+		t.Fatalf("root expressions = %+v, want the split range", got.Root.Expressions)
+	}
+	if link := got.Root.Expressions[0]; link.Type != "Split Range" || link.Style != "dotted" || len(link.Child.Expressions) != 2 {
+		t.Errorf("root expression = %+v, want a dotted Split Range with two operands", link)
+	}
+}
+
+func TestBuildPlanJSON_variableFlows(t *testing.T) {
+	t.Parallel()
+
+	out, err := buildPlanJSON(readPlan(t), `{"variableFlows": true}`)
+	if err != nil {
+		t.Fatalf("buildPlanJSON() error = %v", err)
+	}
+
+	var got jsonPlan
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("unmarshal buildPlanJSON() output: %v", err)
+	}
+	want := jsonVariableFlow{From: "node1", To: "node21", Variables: []string{"$v2"}}
+	if !slices.ContainsFunc(got.VariableFlows, func(flow jsonVariableFlow) bool {
+		return flow.From == want.From && flow.To == want.To && slices.Equal(flow.Variables, want.Variables)
+	}) {
+		t.Errorf("variableFlows = %+v, want %+v", got.VariableFlows, want)
+	}
+}
+
+// TestWASM_node builds the js/wasm binary and exercises the JavaScript API through Node.js.
+func TestWASM_node(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping wasm build in short mode")
+	}
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found in PATH")
+	}
+	wasmExec := filepath.Join(runtime.GOROOT(), "lib", "wasm", "wasm_exec.js")
+	if _, err := os.Stat(wasmExec); err != nil {
+		wasmExec = filepath.Join(runtime.GOROOT(), "misc", "wasm", "wasm_exec.js")
+		if _, err := os.Stat(wasmExec); err != nil {
+			t.Skip("wasm_exec.js not found in GOROOT")
+		}
+	}
+
+	wasmPath := filepath.Join(t.TempDir(), "spannerplanviz.wasm")
+	build := exec.Command("go", "build", "-o", wasmPath, ".")
+	build.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	out, err := exec.Command(node, filepath.Join("testdata", "run_node.js"), wasmExec, wasmPath, testdataPath("dca_profile.json")).Output()
+	if err != nil {
+		t.Fatalf("node: %v", err)
+	}
+
+	var result struct {
+		Mermaid   string `json:"mermaid"`
+		RootID    string `json:"rootID"`
+		Rejection string `json:"rejection"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		t.Fatalf("unmarshal node output %q: %v", out, err)
+	}
+	if !strings.Contains(result.Mermaid, "graph TD") {
+		t.Errorf("renderMermaid() = %q, want Mermaid source", result.Mermaid)
+	}
+	if result.RootID != "node0" {
+		t.Errorf("buildPlanJSON() root id = %q, want node0", result.RootID)
+	}
+	if !strings.Contains(result.Rejection, "unknown field") {
+		t.Errorf("renderMermaid() with invalid options rejected with %q, want unknown field error", result.Rejection)
+	}
+}
@@ -0,0 +1,12 @@
+// Command wasm exposes spannerplanviz to JavaScript when built with GOOS=js GOARCH=wasm.
+//
+// After the module is started with wasm_exec.js, the following functions are available
+// on globalThis.spannerplanviz. Each returns a Promise resolving to a string.
+//
+//	renderMermaid(planJSON, options)  // Mermaid.js source
+//	buildPlanJSON(planJSON, options)  // diagram model as JSON
+//
+// planJSON is the JSON or YAML of a single ResultSet, ResultSetStats or QueryPlan.
+// options is a JSON string or object with visualize.BuildOptions fields
+// (for example {"full": true}); if omitted, visualize.StructureBuildOptions is used.
+package main
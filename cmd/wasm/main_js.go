@@ -0,0 +1,51 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"syscall/js"
+)
+
+func main() {
+	js.Global().Set("spannerplanviz", js.ValueOf(map[string]any{
+		"renderMermaid": promiseFunc(renderMermaid),
+		"buildPlanJSON": promiseFunc(buildPlanJSON),
+	}))
+
+	// Keep the Go runtime alive so that the exported functions remain callable.
+	select {}
+}
+
+// promiseFunc wraps f as a JavaScript function taking (planJSON, options) and returning a Promise.
+func promiseFunc(f func(planJSON, optionsJSON string) (string, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		planJSON := argString(args, 0)
+		optionsJSON := argString(args, 1)
+		if len(args) > 1 && args[1].Type() == js.TypeObject {
+			optionsJSON = js.Global().Get("JSON").Call("stringify", args[1]).String()
+		}
+
+		handler := js.FuncOf(func(this js.Value, promiseArgs []js.Value) any {
+			resolve, reject := promiseArgs[0], promiseArgs[1]
+			go func() {
+				result, err := f(planJSON, optionsJSON)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New(err.Error()))
+					return
+				}
+				resolve.Invoke(result)
+			}()
+			return nil
+		})
+		defer handler.Release()
+
+		return js.Global().Get("Promise").New(handler)
+	})
+}
+
+func argString(args []js.Value, i int) string {
+	if i >= len(args) || args[i].Type() != js.TypeString {
+		return ""
+	}
+	return args[i].String()
+}
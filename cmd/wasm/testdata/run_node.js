@@ -0,0 +1,35 @@
+// Loads the spannerplanviz WASM module in Node.js and prints the results of the exported API as JSON.
+// usage: node run_node.js <wasm_exec.js> <module.wasm> <plan.json>
+"use strict";
+
+globalThis.require = require;
+globalThis.fs = require("fs");
+globalThis.path = require("path");
+globalThis.TextEncoder = require("util").TextEncoder;
+globalThis.TextDecoder = require("util").TextDecoder;
+globalThis.performance ??= require("perf_hooks").performance;
+globalThis.crypto ??= require("crypto");
+
+require(process.argv[2]);
+
+const go = new Go();
+WebAssembly.instantiate(fs.readFileSync(process.argv[3]), go.importObject).then(async (result) => {
+	go.run(result.instance);
+
+	const plan = fs.readFileSync(process.argv[4], "utf8");
+	const mermaid = await spannerplanviz.renderMermaid(plan, { full: true });
+	const model = JSON.parse(await spannerplanviz.buildPlanJSON(plan, '{"metadata": true}'));
+
+	let rejection = "";
+	try {
+		await spannerplanviz.renderMermaid(plan, { unknownOption: true });
+	} catch (e) {
+		rejection = e.message;
+	}
+
+	console.log(JSON.stringify({ mermaid, rootID: model.root.id, rejection }));
+	process.exit(0);
+}).catch((err) => {
+	console.error(err);
+	process.exit(1);
+});
@@ -0,0 +1,124 @@
+// Package fetch runs a query against Cloud Spanner and returns its plan for visualization.
+//
+// The Go client honours SPANNER_EMULATOR_HOST, so Query works against the emulator
+// or any gRPC server implementing the Spanner API.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Mode selects how the query is executed.
+type Mode string
+
+const (
+	// ModePlan only plans the query (AnalyzeQuery); no rows are read.
+	ModePlan Mode = "plan"
+	// ModeProfile executes the query and returns execution statistics.
+	ModeProfile Mode = "profile"
+)
+
+// Request describes the query to run.
+type Request struct {
+	Project  string
+	Instance string
+	Database string
+	SQL      string
+	Mode     Mode
+	// Params are query parameters keyed by name without the leading '@'.
+	// Values may be any type accepted by spanner.Statement, including the results of ParseParams.
+	Params map[string]any
+}
+
+// DatabasePath returns the fully qualified database name.
+func (r Request) DatabasePath() string {
+	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", r.Project, r.Instance, r.Database)
+}
+
+// Validate reports missing fields.
+func (r Request) Validate() error {
+	var errs []error
+	if r.Project == "" {
+		errs = append(errs, errors.New("project is required"))
+	}
+	if r.Instance == "" {
+		errs = append(errs, errors.New("instance is required"))
+	}
+	if r.Database == "" {
+		errs = append(errs, errors.New("database is required"))
+	}
+	if r.SQL == "" {
+		errs = append(errs, errors.New("sql is required"))
+	}
+	switch r.Mode {
+	case ModePlan, ModeProfile:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported mode %q", r.Mode))
+	}
+	return errors.Join(errs...)
+}
+
+// Query connects to the database in req, runs req.SQL and returns the resulting plan and row type.
+func Query(ctx context.Context, req Request, opts ...option.ClientOption) (*sppb.ResultSetStats, *sppb.StructType, error) {
+	if err := req.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	client, err := spanner.NewClientWithConfig(ctx, req.DatabasePath(), spanner.ClientConfig{
+		// A single statement is run, so there is no point in warming up a full session pool.
+		SessionPoolConfig: spanner.SessionPoolConfig{MinOpened: 1},
+	}, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	return QueryWithClient(ctx, client, spanner.Statement{SQL: req.SQL, Params: req.Params}, req.Mode)
+}
+
+// QueryWithClient runs stmt in a single-use read-only transaction and returns the resulting plan and row type.
+// In ModeProfile, all rows are read and discarded so that the returned statistics are complete.
+func QueryWithClient(ctx context.Context, client *spanner.Client, stmt spanner.Statement, mode Mode) (*sppb.ResultSetStats, *sppb.StructType, error) {
+	var queryMode sppb.ExecuteSqlRequest_QueryMode
+	switch mode {
+	case ModePlan:
+		queryMode = sppb.ExecuteSqlRequest_PLAN
+	case ModeProfile:
+		queryMode = sppb.ExecuteSqlRequest_PROFILE
+	default:
+		return nil, nil, fmt.Errorf("unsupported mode %q", mode)
+	}
+
+	iter := client.Single().QueryWithOptions(ctx, stmt, spanner.QueryOptions{Mode: &queryMode})
+	defer iter.Stop()
+
+	for {
+		if _, err := iter.Next(); errors.Is(err, iterator.Done) {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if iter.QueryPlan == nil {
+		return nil, nil, errors.New("server returned no query plan")
+	}
+
+	queryStats, err := structpb.NewStruct(iter.QueryStats)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert query stats: %w", err)
+	}
+
+	return &sppb.ResultSetStats{
+		QueryPlan:  iter.QueryPlan,
+		QueryStats: queryStats,
+	}, iter.Metadata.GetRowType(), nil
+}
@@ -0,0 +1,170 @@
+package fetch_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/apstndb/spannerplanviz/fetch"
+)
+
+// fakeSpanner is a minimal Spanner server that returns a canned plan for every query.
+type fakeSpanner struct {
+	sppb.UnimplementedSpannerServer
+
+	mu       sync.Mutex
+	requests []*sppb.ExecuteSqlRequest
+}
+
+func (s *fakeSpanner) CreateSession(_ context.Context, req *sppb.CreateSessionRequest) (*sppb.Session, error) {
+	return &sppb.Session{Name: req.GetDatabase() + "/sessions/0"}, nil
+}
+
+func (s *fakeSpanner) BatchCreateSessions(_ context.Context, req *sppb.BatchCreateSessionsRequest) (*sppb.BatchCreateSessionsResponse, error) {
+	resp := &sppb.BatchCreateSessionsResponse{}
+	for i := range req.GetSessionCount() {
+		resp.Session = append(resp.Session, &sppb.Session{Name: fmt.Sprintf("%s/sessions/%d", req.GetDatabase(), i)})
+	}
+	return resp, nil
+}
+
+func (s *fakeSpanner) DeleteSession(context.Context, *sppb.DeleteSessionRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func (s *fakeSpanner) ExecuteStreamingSql(req *sppb.ExecuteSqlRequest, stream sppb.Spanner_ExecuteStreamingSqlServer) error {
+	s.mu.Lock()
+	s.requests = append(s.requests, proto.Clone(req).(*sppb.ExecuteSqlRequest))
+	s.mu.Unlock()
+
+	metadata := &sppb.ResultSetMetadata{RowType: &sppb.StructType{Fields: []*sppb.StructType_Field{
+		{Name: "SingerId", Type: &sppb.Type{Code: sppb.TypeCode_INT64}},
+	}}}
+	planNodes := []*sppb.PlanNode{
+		{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Distributed Union", ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 1}}},
+		{Index: 1, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Scan", Metadata: mustStruct(map[string]any{"scan_type": "TableScan", "scan_target": "Singers"})},
+	}
+	stats := &sppb.ResultSetStats{QueryPlan: &sppb.QueryPlan{PlanNodes: planNodes}}
+
+	if req.GetQueryMode() == sppb.ExecuteSqlRequest_PROFILE {
+		if err := stream.Send(&sppb.PartialResultSet{Metadata: metadata, Values: []*structpb.Value{structpb.NewStringValue("1")}}); err != nil {
+			return err
+		}
+		planNodes[0].ExecutionStats = mustStruct(map[string]any{"rows": map[string]any{"total": "1", "unit": "rows"}})
+		stats.QueryStats = mustStruct(map[string]any{"elapsed_time": "1.23 msecs"})
+		return stream.Send(&sppb.PartialResultSet{Stats: stats})
+	}
+	return stream.Send(&sppb.PartialResultSet{Metadata: metadata, Stats: stats})
+}
+
+func (s *fakeSpanner) lastRequest() *sppb.ExecuteSqlRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+func mustStruct(m map[string]any) *structpb.Struct {
+	s, err := structpb.NewStruct(m)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func startFakeSpanner(t *testing.T) *fakeSpanner {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	fake := &fakeSpanner{}
+	server := grpc.NewServer()
+	sppb.RegisterSpannerServer(server, fake)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+
+	t.Setenv("SPANNER_EMULATOR_HOST", lis.Addr().String())
+	return fake
+}
+
+func TestQuery(t *testing.T) {
+	fake := startFakeSpanner(t)
+
+	params, err := fetch.ParseParams([]string{"prefix=Th", "ids:ARRAY<INT64>=[1,2]"})
+	if err != nil {
+		t.Fatalf("ParseParams() error = %v", err)
+	}
+
+	for _, mode := range []fetch.Mode{fetch.ModePlan, fetch.ModeProfile} {
+		t.Run(string(mode), func(t *testing.T) {
+			stats, rowType, err := fetch.Query(context.Background(), fetch.Request{
+				Project:  "p",
+				Instance: "i",
+				Database: "d",
+				SQL:      "SELECT SingerId FROM Singers WHERE STARTS_WITH(FirstName, @prefix) AND SingerId IN UNNEST(@ids)",
+				Mode:     mode,
+				Params:   params,
+			})
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+
+			if got := len(stats.GetQueryPlan().GetPlanNodes()); got != 2 {
+				t.Errorf("plan nodes = %d, want 2", got)
+			}
+			if got := rowType.GetFields()[0].GetName(); got != "SingerId" {
+				t.Errorf("row type field = %q, want SingerId", got)
+			}
+
+			req := fake.lastRequest()
+			wantMode := map[fetch.Mode]sppb.ExecuteSqlRequest_QueryMode{
+				fetch.ModePlan:    sppb.ExecuteSqlRequest_PLAN,
+				fetch.ModeProfile: sppb.ExecuteSqlRequest_PROFILE,
+			}[mode]
+			if req.GetQueryMode() != wantMode {
+				t.Errorf("query mode = %v, want %v", req.GetQueryMode(), wantMode)
+			}
+			if got := req.GetParamTypes()["ids"].GetArrayElementType().GetCode(); got != sppb.TypeCode_INT64 {
+				t.Errorf("param type of ids = %v, want ARRAY<INT64>", got)
+			}
+			if got := req.GetParams().GetFields()["prefix"].GetStringValue(); got != "Th" {
+				t.Errorf("param prefix = %q, want Th", got)
+			}
+
+			if mode == fetch.ModeProfile {
+				if got := stats.GetQueryStats().GetFields()["elapsed_time"].GetStringValue(); got != "1.23 msecs" {
+					t.Errorf("elapsed_time = %q, want 1.23 msecs", got)
+				}
+				if stats.GetQueryPlan().GetPlanNodes()[0].GetExecutionStats() == nil {
+					t.Error("execution stats missing in profile mode")
+				}
+			}
+		})
+	}
+}
+
+func TestRequest_Validate(t *testing.T) {
+	t.Parallel()
+
+	if err := (fetch.Request{}).Validate(); err == nil {
+		t.Fatal("Validate() error = nil, want missing field errors")
+	}
+	valid := fetch.Request{Project: "p", Instance: "i", Database: "d", SQL: "SELECT 1", Mode: fetch.ModePlan}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if got, want := valid.DatabasePath(), "projects/p/instances/i/databases/d"; got != want {
+		t.Fatalf("DatabasePath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+package fetch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var scalarTypeCodes = map[string]sppb.TypeCode{
+	"STRING":    sppb.TypeCode_STRING,
+	"INT64":     sppb.TypeCode_INT64,
+	"FLOAT64":   sppb.TypeCode_FLOAT64,
+	"BOOL":      sppb.TypeCode_BOOL,
+	"BYTES":     sppb.TypeCode_BYTES,
+	"DATE":      sppb.TypeCode_DATE,
+	"TIMESTAMP": sppb.TypeCode_TIMESTAMP,
+	"NUMERIC":   sppb.TypeCode_NUMERIC,
+	"JSON":      sppb.TypeCode_JSON,
+}
+
+// ParseParams parses query parameters in the form name[:TYPE]=value.
+//
+// TYPE is a GoogleSQL scalar type name or ARRAY<scalar> and defaults to STRING.
+// Scalar values are written as plain text (BYTES as base64, TIMESTAMP as RFC 3339).
+// Array values are JSON arrays whose elements are JSON strings, numbers, booleans or null,
+// for example ids:ARRAY<INT64>=[1,2,null].
+func ParseParams(specs []string) (map[string]any, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	params := make(map[string]any, len(specs))
+	for _, spec := range specs {
+		name, value, err := ParseParam(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := params[name]; dup {
+			return nil, fmt.Errorf("duplicate parameter %q", name)
+		}
+		params[name] = value
+	}
+	return params, nil
+}
+
+// ParseParam parses a single name[:TYPE]=value parameter. See ParseParams for the syntax.
+func ParseParam(spec string) (string, spanner.GenericColumnValue, error) {
+	nameType, valueStr, ok := strings.Cut(spec, "=")
+	if !ok {
+		return "", spanner.GenericColumnValue{}, fmt.Errorf("invalid parameter %q: want name[:TYPE]=value", spec)
+	}
+
+	name, typeStr, hasType := strings.Cut(nameType, ":")
+	name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+	if name == "" {
+		return "", spanner.GenericColumnValue{}, fmt.Errorf("invalid parameter %q: empty name", spec)
+	}
+	if !hasType {
+		typeStr = "STRING"
+	}
+
+	typ, err := parseType(typeStr)
+	if err != nil {
+		return "", spanner.GenericColumnValue{}, fmt.Errorf("invalid parameter %q: %w", name, err)
+	}
+
+	value, err := parseValue(typ, valueStr)
+	if err != nil {
+		return "", spanner.GenericColumnValue{}, fmt.Errorf("invalid parameter %q: %w", name, err)
+	}
+	return name, spanner.GenericColumnValue{Type: typ, Value: value}, nil
+}
+
+func parseType(s string) (*sppb.Type, error) {
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	if elem, ok := strings.CutPrefix(s, "ARRAY<"); ok {
+		elem, ok = strings.CutSuffix(elem, ">")
+		if !ok {
+			return nil, fmt.Errorf("invalid type %q", s)
+		}
+		code, ok := scalarTypeCodes[elem]
+		if !ok {
+			return nil, fmt.Errorf("unsupported array element type %q", elem)
+		}
+		return &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: &sppb.Type{Code: code}}, nil
+	}
+
+	code, ok := scalarTypeCodes[s]
+	if !ok {
+		return nil, fmt.Errorf("unsupported type %q", s)
+	}
+	return &sppb.Type{Code: code}, nil
+}
+
+func parseValue(typ *sppb.Type, s string) (*structpb.Value, error) {
+	if typ.GetCode() != sppb.TypeCode_ARRAY {
+		return parseScalarValue(typ.GetCode(), s)
+	}
+
+	var elems []json.RawMessage
+	if err := json.Unmarshal([]byte(s), &elems); err != nil {
+		return nil, fmt.Errorf("array value must be a JSON array: %w", err)
+	}
+
+	values := make([]*structpb.Value, 0, len(elems))
+	for i, raw := range elems {
+		var elemStr string
+		switch {
+		case string(raw) == "null":
+			values = append(values, structpb.NewNullValue())
+			continue
+		case json.Unmarshal(raw, &elemStr) == nil:
+		default:
+			elemStr = string(raw)
+		}
+
+		v, err := parseScalarValue(typ.GetArrayElementType().GetCode(), elemStr)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		values = append(values, v)
+	}
+	return structpb.NewListValue(&structpb.ListValue{Values: values}), nil
+}
+
+// parseScalarValue validates s and encodes it in the Spanner wire format for code.
+func parseScalarValue(code sppb.TypeCode, s string) (*structpb.Value, error) {
+	switch code {
+	case sppb.TypeCode_STRING:
+		return structpb.NewStringValue(s), nil
+	case sppb.TypeCode_INT64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewStringValue(strconv.FormatInt(i, 10)), nil
+	case sppb.TypeCode_FLOAT64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case math.IsNaN(f):
+			return structpb.NewStringValue("NaN"), nil
+		case math.IsInf(f, 1):
+			return structpb.NewStringValue("Infinity"), nil
+		case math.IsInf(f, -1):
+			return structpb.NewStringValue("-Infinity"), nil
+		}
+		return structpb.NewNumberValue(f), nil
+	case sppb.TypeCode_BOOL:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewBoolValue(b), nil
+	case sppb.TypeCode_BYTES:
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			return nil, fmt.Errorf("BYTES value must be base64: %w", err)
+		}
+		return structpb.NewStringValue(s), nil
+	case sppb.TypeCode_DATE:
+		if _, err := time.Parse(time.DateOnly, s); err != nil {
+			return nil, err
+		}
+		return structpb.NewStringValue(s), nil
+	case sppb.TypeCode_TIMESTAMP:
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewStringValue(t.UTC().Format(time.RFC3339Nano)), nil
+	case sppb.TypeCode_NUMERIC:
+		if _, ok := new(big.Rat).SetString(s); !ok {
+			return nil, fmt.Errorf("invalid NUMERIC value %q", s)
+		}
+		return structpb.NewStringValue(s), nil
+	case sppb.TypeCode_JSON:
+		if !json.Valid([]byte(s)) {
+			return nil, fmt.Errorf("invalid JSON value %q", s)
+		}
+		return structpb.NewStringValue(s), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %v", code)
+	}
+}
@@ -0,0 +1,95 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestParseParam(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		spec      string
+		wantName  string
+		wantType  string
+		wantValue string
+		wantErr   bool
+	}{
+		{spec: "prefix=Th", wantName: "prefix", wantType: `{"code":"STRING"}`, wantValue: `"Th"`},
+		{spec: "@id:INT64=42", wantName: "id", wantType: `{"code":"INT64"}`, wantValue: `"42"`},
+		{spec: "f:float64=1.5", wantName: "f", wantType: `{"code":"FLOAT64"}`, wantValue: `1.5`},
+		{spec: "f:FLOAT64=NaN", wantName: "f", wantType: `{"code":"FLOAT64"}`, wantValue: `"NaN"`},
+		{spec: "b:BOOL=true", wantName: "b", wantType: `{"code":"BOOL"}`, wantValue: `true`},
+		{spec: "d:DATE=2024-01-02", wantName: "d", wantType: `{"code":"DATE"}`, wantValue: `"2024-01-02"`},
+		{spec: "ts:TIMESTAMP=2024-01-02T03:04:05+09:00", wantName: "ts", wantType: `{"code":"TIMESTAMP"}`, wantValue: `"2024-01-01T18:04:05Z"`},
+		{spec: "n:NUMERIC=1.25", wantName: "n", wantType: `{"code":"NUMERIC"}`, wantValue: `"1.25"`},
+		{spec: `j:JSON={"a":1}`, wantName: "j", wantType: `{"code":"JSON"}`, wantValue: `"{\"a\":1}"`},
+		{spec: "by:BYTES=AQI=", wantName: "by", wantType: `{"code":"BYTES"}`, wantValue: `"AQI="`},
+		{spec: "ids:ARRAY<INT64>=[1, \"2\", null]", wantName: "ids", wantType: `{"code":"ARRAY","arrayElementType":{"code":"INT64"}}`, wantValue: `["1","2",null]`},
+		{spec: "names:ARRAY<STRING>=[\"a\",\"b\"]", wantName: "names", wantType: `{"code":"ARRAY","arrayElementType":{"code":"STRING"}}`, wantValue: `["a","b"]`},
+		{spec: "missing-equals", wantErr: true},
+		{spec: ":INT64=1", wantErr: true},
+		{spec: "id:INT64=abc", wantErr: true},
+		{spec: "id:STRUCT<a INT64>=1", wantErr: true},
+		{spec: "ids:ARRAY<INT64>=1", wantErr: true},
+		{spec: "ids:ARRAY<INT64>=[\"x\"]", wantErr: true},
+		{spec: "by:BYTES=!!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+
+			name, gcv, err := ParseParam(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseParam(%q) error = nil, want error", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseParam(%q) error = %v", tt.spec, err)
+			}
+			if name != tt.wantName {
+				t.Errorf("name = %q, want %q", name, tt.wantName)
+			}
+
+			typ, _ := protojson.Marshal(gcv.Type)
+			var wantType sppb.Type
+			if err := protojson.Unmarshal([]byte(tt.wantType), &wantType); err != nil {
+				t.Fatalf("invalid wantType: %v", err)
+			}
+			gotType, _ := protojson.Marshal(&wantType)
+			if diff := cmp.Diff(string(gotType), string(typ)); diff != "" {
+				t.Errorf("type mismatch (-want +got):\n%s", diff)
+			}
+
+			value, _ := protojson.Marshal(gcv.Value)
+			if diff := cmp.Diff(tt.wantValue, compactJSON(string(value))); diff != "" {
+				t.Errorf("value mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseParams_duplicate(t *testing.T) {
+	t.Parallel()
+
+	if _, err := ParseParams([]string{"a=1", "@a=2"}); err == nil {
+		t.Fatal("ParseParams() error = nil, want duplicate error")
+	}
+}
+
+// compactJSON removes the insignificant whitespace protojson randomly inserts.
+func compactJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(s)); err != nil {
+		return s
+	}
+	return buf.String()
+}
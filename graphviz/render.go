@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strings"
 
 	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
 	"github.com/apstndb/spannerplanviz/visualize"
@@ -63,23 +64,33 @@ func renderGraph(graph *cgraph.Graph, plan *visualize.Plan, opts Options) error
 	if err := renderTree(graph, plan.Root, plan); err != nil {
 		return err
 	}
+	for _, flow := range plan.VariableFlows {
+		if err := renderVariableFlow(graph, flow); err != nil {
+			return err
+		}
+	}
 
 	needQueryNode := (opts.ShowQuery || opts.ShowQueryStats) && plan.QueryStats != nil
 	if needQueryNode {
-		if err := renderQueryNodeWithEdge(graph, plan.QueryStats, opts.ShowQueryStats, plan.Root.GetName()); err != nil {
+		if err := renderQueryNodeWithEdge(graph, plan.QueryStats, opts.ShowQueryStats, plan.Root.GetName(), plan.Build); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func renderQueryNodeWithEdge(graph *cgraph.Graph, queryStats *sppb.ResultSetStats, showQueryStats bool, rootName string) error {
-	str := visualize.FormatQueryNode(queryStats.GetQueryStats().GetFields(), showQueryStats)
+func renderQueryNodeWithEdge(graph *cgraph.Graph, queryStats *sppb.ResultSetStats, showQueryStats bool, rootName string, param visualize.BuildOptions) error {
+	fields := queryStats.GetQueryStats().GetFields()
+	str := visualize.FormatQueryNodeWithOptions(fields, showQueryStats, param)
 
 	n, err := renderQueryNode(graph, str)
 	if err != nil {
 		return err
 	}
+	if param.MaxLines > 0 {
+		// The query text may be truncated in the label.
+		n.SetTooltip(visualize.QueryText(fields))
+	}
 
 	gvRootNode, err := graph.NodeByName(rootName)
 	if err != nil {
@@ -106,6 +117,28 @@ func renderTree(graph *cgraph.Graph, node *visualize.TreeNode, plan *visualize.P
 			return err
 		}
 	}
+	return renderExpressions(graph, node, plan)
+}
+
+// renderExpressions renders the scalar expression trees of node as ellipses.
+func renderExpressions(graph *cgraph.Graph, node *visualize.TreeNode, plan *visualize.Plan) error {
+	for _, link := range node.ExpressionLinks() {
+		if err := renderNode(graph, link.ChildNode, plan); err != nil {
+			return err
+		}
+		n, err := graph.NodeByName(link.ChildNode.GetName())
+		if err != nil {
+			return err
+		}
+		n.SetShape(cgraph.EllipseShape)
+
+		if err := renderExpressions(graph, link.ChildNode, plan); err != nil {
+			return err
+		}
+		if err := renderEdge(graph, node, link); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -117,7 +150,7 @@ func renderNode(graph *cgraph.Graph, node *visualize.TreeNode, plan *visualize.P
 
 	n.SetShape(cgraph.BoxShape)
 
-	tooltipStr, err := node.GetTooltip()
+	tooltipStr, err := node.Tooltip(plan.Build, plan.RowType)
 	if err != nil {
 		return fmt.Errorf("error getting tooltip for node %s: %w", node.GetName(), err)
 	}
@@ -131,6 +164,22 @@ func renderNode(graph *cgraph.Graph, node *visualize.TreeNode, plan *visualize.P
 	}
 
 	n.SetLabel(nodeHTML)
+
+	if color := node.OutlineColor(); color != "" {
+		n.SetColor(color)
+		n.SetPenWidth(2)
+	}
+	var styles []string
+	if node.OmittedOperators() > 0 {
+		styles = append(styles, string(cgraph.DashedNodeStyle))
+	}
+	if node.IsHighlighted() {
+		styles = append(styles, string(cgraph.FilledNodeStyle))
+		n.SetFillColor(visualize.HighlightColor)
+	}
+	if len(styles) > 0 {
+		n.SetStyle(cgraph.NodeStyle(strings.Join(styles, ",")))
+	}
 	return nil
 }
 
@@ -152,6 +201,36 @@ func renderEdge(graph *cgraph.Graph, parent *visualize.TreeNode, edge *visualize
 
 	ed.SetStyle(toCgraphEdgeStyle(edge.Style))
 	ed.SetLabel(edge.ChildType)
+	if edge.Highlighted {
+		if edge.Style == visualize.EdgeStyleSolid {
+			ed.SetStyle(cgraph.BoldEdgeStyle)
+		}
+		ed.SetColor("red")
+		ed.SetPenWidth(2)
+	}
+	return nil
+}
+
+// renderVariableFlow draws flow without affecting the ranks of the operators.
+func renderVariableFlow(graph *cgraph.Graph, flow visualize.VariableFlow) error {
+	from, err := graph.NodeByName(flow.From.GetName())
+	if err != nil {
+		return err
+	}
+	to, err := graph.NodeByName(flow.To.GetName())
+	if err != nil {
+		return err
+	}
+
+	ed, err := graph.CreateEdgeByName("", from, to)
+	if err != nil {
+		return err
+	}
+	ed.SetStyle(cgraph.DashedEdgeStyle)
+	ed.SetColor(visualize.VariableFlowColor)
+	ed.SetFontColor(visualize.VariableFlowColor)
+	ed.SetConstraint(false)
+	ed.SetLabel(strings.Join(flow.Variables, "\n"))
 	return nil
 }
 
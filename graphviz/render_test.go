@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/goccy/go-graphviz/cgraph"
 	"github.com/google/go-cmp/cmp"
 	"google.golang.org/protobuf/encoding/protojson"
 
@@ -179,3 +180,203 @@ func TestRenderer_requiresFormat(t *testing.T) {
 		t.Fatal("Render() error = nil, want missing format error")
 	}
 }
+
+// dotAttrs are attributes of a node or an edge of the rendered DOT graph. A "label" or "tooltip"
+// value only needs to be contained in the rendered attribute.
+type dotAttrs map[string]string
+
+func TestRenderer_features(t *testing.T) {
+	dcaProfile := resultSet(t, "dca_profile.json")
+	unionAll := []*sppb.PlanNode{
+		{Index: 0, DisplayName: "Union All", Kind: sppb.PlanNode_RELATIONAL, ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 1}}},
+		{Index: 1, DisplayName: "Scan", Kind: sppb.PlanNode_RELATIONAL},
+	}
+
+	for _, tt := range []struct {
+		desc    string
+		stats   *sppb.ResultSetStats
+		rowType *sppb.StructType
+		opts    visualize.BuildOptions
+		render  graphviz.Options
+		edit    func(plan *visualize.Plan)
+		// nodes are keyed by node name and edges by "tail -> head"; tree edges point from
+		// the child to the parent.
+		nodes map[string]dotAttrs
+		edges map[string]dotAttrs
+	}{
+		{
+			desc:  "annotations",
+			stats: planStats(unionAll...),
+			edit: func(plan *visualize.Plan) {
+				plan.Root.AddAnnotation(visualize.Annotation{Text: "spills", Color: "red"})
+			},
+			nodes: map[string]dotAttrs{
+				"node0": {"color": "red", "penwidth": "2", "label": `<font color="red">spills</font>`},
+				"node1": {"color": "black"},
+			},
+		},
+		{
+			desc:  "highlighted links",
+			stats: planStats(unionAll...),
+			edit: func(plan *visualize.Plan) {
+				plan.Root.Children[0].Highlighted = true
+			},
+			edges: map[string]dotAttrs{"node1 -> node0": {"color": "red", "penwidth": "2", "style": "bold"}},
+		},
+		{
+			desc:  "highlighted nodes",
+			stats: planStats(unionAll...),
+			opts:  visualize.BuildOptions{Highlight: `display_name == "Scan"`},
+			nodes: map[string]dotAttrs{
+				"node0": {"style": ""},
+				"node1": {"style": "filled", "fillcolor": "lightyellow"},
+			},
+		},
+		{
+			desc:  "highlighted placeholder",
+			stats: planStats(unionAll...),
+			opts:  visualize.BuildOptions{MaxDepth: 1},
+			edit: func(plan *visualize.Plan) {
+				plan.Root.Children[0].ChildNode.Highlighted = true
+			},
+			nodes: map[string]dotAttrs{"node1_omitted": {"style": "dashed,filled", "fillcolor": "lightyellow", "label": "… 1 more operator"}},
+			edges: map[string]dotAttrs{"node1_omitted -> node0": {"style": "solid"}},
+		},
+		{
+			desc:    "truncated text",
+			stats:   dcaProfile.GetStats(),
+			rowType: dcaProfile.GetMetadata().GetRowType(),
+			opts:    visualize.BuildOptions{NonVariableScalar: true, WrapWidth: 24, MaxLines: 1},
+			render:  graphviz.Options{ShowQuery: true},
+			nodes: map[string]dotAttrs{
+				"node29": {"label": "Seek Condition:…", "tooltip": "Seek Condition: ($SingerId_1 = $batched_SingerId)"},
+				"query":  {"label": "SELECT * FROM Singers…", "tooltip": "SELECT * FROM Singers JOIN Songs"},
+			},
+		},
+		{
+			desc: "scalar expressions",
+			stats: planStats(
+				&sppb.PlanNode{Index: 0, DisplayName: "Filter", Kind: sppb.PlanNode_RELATIONAL, ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 1}, {ChildIndex: 2, Type: "Condition"}}},
+				&sppb.PlanNode{Index: 1, DisplayName: "Scan", Kind: sppb.PlanNode_RELATIONAL},
+				&sppb.PlanNode{Index: 2, DisplayName: "Reference", Kind: sppb.PlanNode_SCALAR, ShortRepresentation: &sppb.PlanNode_ShortRepresentation{Description: "$x"}},
+			),
+			opts: visualize.BuildOptions{ScalarExpressions: true},
+			nodes: map[string]dotAttrs{
+				"node1": {"shape": "box"},
+				"node2": {"shape": "ellipse", "label": "$x"},
+			},
+			edges: map[string]dotAttrs{
+				"node1 -> node0": {"style": "solid"},
+				"node2 -> node0": {"style": "dotted", "label": "Condition"},
+			},
+		},
+		{
+			desc: "variable flows",
+			stats: planStats(
+				&sppb.PlanNode{Index: 0, DisplayName: "Cross Apply", Kind: sppb.PlanNode_RELATIONAL, ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 1}, {ChildIndex: 3}}},
+				&sppb.PlanNode{Index: 1, DisplayName: "Scan", Kind: sppb.PlanNode_RELATIONAL, ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 2, Variable: "x"}}},
+				&sppb.PlanNode{Index: 2, DisplayName: "Reference", Kind: sppb.PlanNode_SCALAR, ShortRepresentation: &sppb.PlanNode_ShortRepresentation{Description: "x"}},
+				&sppb.PlanNode{Index: 3, DisplayName: "Filter", Kind: sppb.PlanNode_RELATIONAL, ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 4, Type: "Condition"}}},
+				&sppb.PlanNode{Index: 4, DisplayName: "Reference", Kind: sppb.PlanNode_SCALAR, ShortRepresentation: &sppb.PlanNode_ShortRepresentation{Description: "$x"}},
+			),
+			opts: visualize.BuildOptions{VariableFlows: true},
+			edges: map[string]dotAttrs{
+				"node1 -> node0": {"color": "black"},
+				"node1 -> node3": {"color": "steelblue", "fontcolor": "steelblue", "style": "dashed", "constraint": "false", "label": "$x"},
+			},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			plan, err := visualize.BuildPlan(tt.rowType, tt.stats, tt.opts)
+			if err != nil {
+				t.Fatalf("BuildPlan() error = %v", err)
+			}
+			if tt.edit != nil {
+				tt.edit(plan)
+			}
+
+			var buf bytes.Buffer
+			tt.render.Format = graphviz.DOT
+			if err := graphviz.NewRenderer(tt.render).Render(context.Background(), &buf, plan); err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			graph, err := cgraph.ParseBytes(buf.Bytes())
+			if err != nil {
+				t.Fatalf("ParseBytes() error = %v", err)
+			}
+
+			for name, want := range tt.nodes {
+				n, err := graph.NodeByName(name)
+				if err != nil || n == nil {
+					t.Errorf("node %s not found in %s", name, buf.String())
+					continue
+				}
+				checkAttrs(t, name, n.GetStr, want)
+			}
+			for name, want := range tt.edges {
+				e := findEdge(t, graph, name)
+				if e == nil {
+					t.Errorf("edge %s not found in %s", name, buf.String())
+					continue
+				}
+				checkAttrs(t, name, e.GetStr, want)
+			}
+		})
+	}
+}
+
+func planStats(nodes ...*sppb.PlanNode) *sppb.ResultSetStats {
+	return &sppb.ResultSetStats{QueryPlan: &sppb.QueryPlan{PlanNodes: nodes}}
+}
+
+func resultSet(t *testing.T, name string) *sppb.ResultSet {
+	t.Helper()
+
+	b, err := os.ReadFile(testdataPath(name))
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	var rs sppb.ResultSet
+	if err := protojson.Unmarshal(b, &rs); err != nil {
+		t.Fatalf("unmarshal %s: %v", name, err)
+	}
+	return &rs
+}
+
+// findEdge returns the edge "tail -> head" of graph, or nil.
+func findEdge(t *testing.T, graph *cgraph.Graph, name string) *cgraph.Edge {
+	t.Helper()
+
+	tailName, headName, _ := strings.Cut(name, " -> ")
+	tail, err := graph.NodeByName(tailName)
+	if err != nil || tail == nil {
+		return nil
+	}
+	for e, _ := graph.FirstOut(tail); e != nil; e, _ = graph.NextOut(e) {
+		head, err := e.Head()
+		if err != nil {
+			t.Fatalf("Head() error = %v", err)
+		}
+		if got, _ := head.Name(); got == headName {
+			return e
+		}
+	}
+	return nil
+}
+
+func checkAttrs(t *testing.T, name string, get func(string) string, want dotAttrs) {
+	t.Helper()
+
+	for attr, value := range want {
+		got := get(attr)
+		if attr == "label" || attr == "tooltip" {
+			if !strings.Contains(got, value) {
+				t.Errorf("%s %s = %q, want it to contain %q", name, attr, got, value)
+			}
+			continue
+		}
+		if got != value {
+			t.Errorf("%s %s = %q, want %q", name, attr, got, value)
+		}
+	}
+}
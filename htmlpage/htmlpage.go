@@ -0,0 +1,118 @@
+// Package htmlpage renders several plans into a single self-contained HTML page.
+package htmlpage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+
+	"github.com/apstndb/spannerplanviz/graphviz"
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+// Section is one plan on the page.
+type Section struct {
+	Title string
+	Plan  *visualize.Plan
+}
+
+// Options configures the page.
+type Options struct {
+	// Title is the document title.
+	Title string
+	// ShowQuery and ShowQueryStats are passed to the Graphviz renderer.
+	ShowQuery      bool
+	ShowQueryStats bool
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; }
+nav ol { columns: 3; }
+section { margin-bottom: 2em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{- if gt (len .Sections) 1}}
+<nav><ol>
+{{- range $i, $s := .Sections}}
+<li><a href="#plan-{{$i}}">{{$s.Title}}</a></li>
+{{- end}}
+</ol></nav>
+{{- end}}
+{{- range $i, $s := .Sections}}
+<section id="plan-{{$i}}">
+<h2>{{$s.Title}}</h2>
+{{$s.SVG}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+type renderedSection struct {
+	Title string
+	SVG   template.HTML
+}
+
+// Write renders every section as inline SVG and writes the page to w.
+func Write(ctx context.Context, w io.Writer, sections []Section, opts Options) error {
+	renderer := graphviz.NewRenderer(graphviz.Options{
+		Format:         graphviz.SVG,
+		ShowQuery:      opts.ShowQuery,
+		ShowQueryStats: opts.ShowQueryStats,
+	})
+
+	rendered := make([]renderedSection, 0, len(sections))
+	for i, section := range sections {
+		var buf bytes.Buffer
+		if err := renderer.Render(ctx, &buf, section.Plan); err != nil {
+			return fmt.Errorf("failed to render plan %d: %w", i, err)
+		}
+		title := section.Title
+		if title == "" {
+			title = fmt.Sprintf("Plan %d", i)
+		}
+		rendered = append(rendered, renderedSection{
+			Title: title,
+			// The SVG is produced by Graphviz from escaped labels, so it is trusted markup.
+			SVG: template.HTML(prefixIDs(stripXMLProlog(buf.Bytes()), fmt.Sprintf("plan-%d-", i))),
+		})
+	}
+
+	title := opts.Title
+	if title == "" {
+		title = "Query plans"
+	}
+	return pageTemplate.Execute(w, struct {
+		Title    string
+		Sections []renderedSection
+	}{Title: title, Sections: rendered})
+}
+
+// stripXMLProlog drops the XML declaration and DOCTYPE that precede the <svg> element,
+// which are not allowed inside an HTML document.
+func stripXMLProlog(svg []byte) []byte {
+	if i := bytes.Index(svg, []byte("<svg")); i >= 0 {
+		return svg[i:]
+	}
+	return svg
+}
+
+// svgIDRef matches id attributes and the fragment references to them in Graphviz SVG.
+var svgIDRef = regexp.MustCompile(`\b(id="|href="#|url\(#)([^")]+)`)
+
+// prefixIDs prepends prefix to every id in svg and to the references to them, because
+// Graphviz numbers ids from graph0, node1 and edge1 in every SVG and ids must be unique
+// in the page.
+func prefixIDs(svg []byte, prefix string) []byte {
+	return svgIDRef.ReplaceAll(svg, []byte("${1}"+prefix+"${2}"))
+}
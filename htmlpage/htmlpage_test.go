@@ -0,0 +1,69 @@
+package htmlpage_test
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+
+	"github.com/apstndb/spannerplanviz/htmlpage"
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+func buildPlan(t *testing.T, displayName string) *visualize.Plan {
+	t.Helper()
+
+	plan, err := visualize.BuildPlan(nil, &sppb.ResultSetStats{QueryPlan: &sppb.QueryPlan{PlanNodes: []*sppb.PlanNode{
+		{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: displayName},
+	}}}, visualize.BuildOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+	return plan
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := htmlpage.Write(context.Background(), &buf, []htmlpage.Section{
+		{Title: "first <query>", Plan: buildPlan(t, "Scan")},
+		{Plan: buildPlan(t, "Distributed Union")},
+	}, htmlpage.Options{})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	page := buf.String()
+	for _, want := range []string{
+		"<title>Query plans</title>",
+		`<section id="plan-0">`,
+		"<h2>first &lt;query&gt;</h2>",
+		"<h2>Plan 1</h2>",
+		"<svg",
+		"Scan",
+		"Distributed Union",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+	ids := map[string]bool{}
+	for _, m := range regexp.MustCompile(` id="([^"]*)"`).FindAllStringSubmatch(page, -1) {
+		if ids[m[1]] {
+			t.Errorf("page contains duplicate id %q", m[1])
+		}
+		ids[m[1]] = true
+	}
+	for _, want := range []string{"plan-0-graph0", "plan-1-graph0"} {
+		if !ids[want] {
+			t.Errorf("page does not contain id %q", want)
+		}
+	}
+	if strings.Contains(page, "<?xml") || strings.Contains(page, "<!DOCTYPE svg") {
+		t.Error("page contains the SVG prolog")
+	}
+}
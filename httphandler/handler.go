@@ -0,0 +1,176 @@
+// Package httphandler exposes plan visualization as an embeddable net/http handler.
+package httphandler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/apstndb/spannerplanviz/graphviz"
+	"github.com/apstndb/spannerplanviz/mermaid"
+	"github.com/apstndb/spannerplanviz/planinput"
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+// Media types served by DefaultRenderers.
+const (
+	MediaTypeSVG      = "image/svg+xml"
+	MediaTypePNG      = "image/png"
+	MediaTypeGraphviz = "text/vnd.graphviz"
+	MediaTypeMermaid  = "text/vnd.mermaid"
+)
+
+// defaultMaxBodyBytes bounds request bodies when Options.MaxBodyBytes is zero.
+const defaultMaxBodyBytes = 64 << 20
+
+// MediaRenderer associates a response media type with the renderer producing it.
+type MediaRenderer struct {
+	MediaType string
+	Renderer  visualize.Renderer
+}
+
+// Options configures Handler.
+type Options struct {
+	// BuildOptions controls which plan details are included in every response.
+	BuildOptions visualize.BuildOptions
+
+	// Renderers lists available output formats in server preference order.
+	// The first entry is used when the request has no Accept header or accepts */*.
+	// If empty, DefaultRenderers(false, false) is used.
+	Renderers []MediaRenderer
+
+	// MaxBodyBytes limits the request body size, both as sent and after decompression.
+	// Zero means 64 MiB; negative means no limit.
+	MaxBodyBytes int64
+}
+
+// DefaultRenderers returns SVG, PNG, DOT and Mermaid renderers in that preference order.
+func DefaultRenderers(showQuery, showQueryStats bool) []MediaRenderer {
+	gv := func(format graphviz.Format) visualize.Renderer {
+		return graphviz.NewRenderer(graphviz.Options{
+			Format:         format,
+			ShowQuery:      showQuery,
+			ShowQueryStats: showQueryStats,
+		})
+	}
+	return []MediaRenderer{
+		{MediaType: MediaTypeSVG, Renderer: gv(graphviz.SVG)},
+		{MediaType: MediaTypePNG, Renderer: gv(graphviz.PNG)},
+		{MediaType: MediaTypeGraphviz, Renderer: gv(graphviz.DOT)},
+		{MediaType: MediaTypeMermaid, Renderer: visualize.RendererFunc(renderMermaid)},
+	}
+}
+
+// renderMermaid renders with the build options the plan was built with,
+// matching the CLI behavior of --type=mermaid.
+func renderMermaid(ctx context.Context, w io.Writer, plan *visualize.Plan) error {
+	return mermaid.NewRenderer(mermaid.Options{BuildOptions: plan.Build}).Render(ctx, w, plan)
+}
+
+// Handler renders plan payloads posted in the request body.
+// The body is a single ResultSet, ResultSetStats or QueryPlan as JSON, YAML, prototext or
+// binary protobuf, optionally gzip or zstd compressed; the format is detected as by
+// planinput.Extract with planinput.FormatAuto. Multi-document input and QUERY_PROFILES
+// exports are not accepted.
+type Handler struct {
+	opts Options
+}
+
+// New returns a Handler configured by opts.
+func New(opts Options) *Handler {
+	if len(opts.Renderers) == 0 {
+		opts.Renderers = DefaultRenderers(false, false)
+	}
+	if opts.MaxBodyBytes == 0 {
+		opts.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	return &Handler{opts: opts}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mr, ok := negotiate(r.Header.Values("Accept"), h.opts.Renderers)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no acceptable media type; available: %s", h.mediaTypes()), http.StatusNotAcceptable)
+		return
+	}
+
+	b, err := h.readBody(w, r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	queryStats, rowType, err := planinput.Extract(b, planinput.FormatAuto)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid plan: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := visualize.BuildPlan(rowType, queryStats, h.opts.BuildOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Render into a buffer so that a failure can still be reported with a proper status code.
+	var buf bytes.Buffer
+	if err := mr.Renderer.Render(r.Context(), &buf, plan); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", mr.MediaType)
+	w.Header().Add("Vary", "Accept")
+	_, _ = buf.WriteTo(w)
+}
+
+// readBody reads the request body, decompressing it if it starts with gzip or zstd magic bytes.
+// Exceeding Options.MaxBodyBytes before or after decompression is reported as *http.MaxBytesError.
+func (h *Handler) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	limit := h.opts.MaxBodyBytes
+	body := io.Reader(r.Body)
+	if limit > 0 {
+		body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	rc, err := planinput.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	if limit <= 0 {
+		return io.ReadAll(rc)
+	}
+	b, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, &http.MaxBytesError{Limit: limit}
+	}
+	return b, nil
+}
+
+func (h *Handler) mediaTypes() string {
+	types := make([]string, 0, len(h.opts.Renderers))
+	for _, mr := range h.opts.Renderers {
+		types = append(types, mr.MediaType)
+	}
+	return strings.Join(types, ", ")
+}
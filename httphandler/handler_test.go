@@ -0,0 +1,159 @@
+package httphandler_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/apstndb/spannerplanviz/httphandler"
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+const simplePlan = `{"queryPlan": {"planNodes": [
+  {"index": 0, "kind": "RELATIONAL", "displayName": "Distributed Union", "childLinks": [{"childIndex": 1}]},
+  {"index": 1, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"}}
+]}}`
+
+func TestHandler_contentNegotiation(t *testing.T) {
+	t.Parallel()
+
+	h := httphandler.New(httphandler.Options{BuildOptions: visualize.StructureBuildOptions()})
+
+	tests := []struct {
+		name        string
+		accept      string
+		wantType    string
+		wantContain string
+	}{
+		{name: "no accept defaults to svg", accept: "", wantType: httphandler.MediaTypeSVG, wantContain: "<svg"},
+		{name: "wildcard", accept: "*/*", wantType: httphandler.MediaTypeSVG, wantContain: "<svg"},
+		{name: "mermaid", accept: "text/vnd.mermaid", wantType: httphandler.MediaTypeMermaid, wantContain: "graph TD"},
+		{name: "dot", accept: "text/vnd.graphviz", wantType: httphandler.MediaTypeGraphviz, wantContain: "digraph"},
+		{name: "png", accept: "image/png", wantType: httphandler.MediaTypePNG, wantContain: "PNG"},
+		{name: "q-values", accept: "image/svg+xml;q=0.5, text/vnd.mermaid;q=0.9", wantType: httphandler.MediaTypeMermaid, wantContain: "graph TD"},
+		{name: "subtype wildcard", accept: "text/*", wantType: httphandler.MediaTypeGraphviz, wantContain: "digraph"},
+		{name: "specific range overrides wildcard", accept: "image/*, image/svg+xml;q=0", wantType: httphandler.MediaTypePNG, wantContain: "PNG"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(simplePlan))
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200; body = %s", rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Fatalf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantContain) {
+				t.Fatalf("body does not contain %q", tt.wantContain)
+			}
+		})
+	}
+}
+
+func TestHandler_errors(t *testing.T) {
+	t.Parallel()
+
+	h := httphandler.New(httphandler.Options{MaxBodyBytes: 1024})
+
+	tests := []struct {
+		name       string
+		method     string
+		accept     string
+		body       string
+		wantStatus int
+	}{
+		{name: "method not allowed", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+		{name: "not acceptable", method: http.MethodPost, accept: "application/pdf", body: simplePlan, wantStatus: http.StatusNotAcceptable},
+		{name: "invalid input", method: http.MethodPost, body: `{"foo": 1}`, wantStatus: http.StatusBadRequest},
+		{name: "empty plan", method: http.MethodPost, body: `{"queryPlan": {"planNodes": []}}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "body too large", method: http.MethodPost, body: strings.Repeat(" ", 2048) + simplePlan, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "decompressed body too large", method: http.MethodPost, body: gzipString(t, strings.Repeat(" ", 2048)+simplePlan), wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body = %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func gzipString(t *testing.T, s string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestHandler_inputFormats(t *testing.T) {
+	t.Parallel()
+
+	var stats sppb.ResultSetStats
+	if err := protojson.Unmarshal([]byte(simplePlan), &stats); err != nil {
+		t.Fatal(err)
+	}
+	binary, err := proto.Marshal(&stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := httphandler.New(httphandler.Options{})
+	for _, tt := range []struct {
+		name string
+		body string
+	}{
+		{"json", simplePlan},
+		{"gzip", gzipString(t, simplePlan)},
+		{"prototext", prototext.Format(&stats)},
+		{"protobuf", string(binary)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Accept", httphandler.MediaTypeMermaid)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200; body = %s", rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), "Table&nbsp;Scan") {
+				t.Errorf("body = %q, want the scan", rec.Body.String())
+			}
+		})
+	}
+}
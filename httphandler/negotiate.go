@@ -0,0 +1,89 @@
+package httphandler
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses Accept header values into media ranges.
+// Malformed ranges are skipped rather than failing the whole header.
+func parseAccept(values []string) []acceptRange {
+	var ranges []acceptRange
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+			typ, subtype, ok := strings.Cut(mediaType, "/")
+			if !ok {
+				continue
+			}
+			q := 1.0
+			if qStr, ok := params["q"]; ok {
+				parsed, err := strconv.ParseFloat(qStr, 64)
+				if err != nil || parsed < 0 || parsed > 1 {
+					continue
+				}
+				q = parsed
+			}
+			ranges = append(ranges, acceptRange{typ: typ, subtype: subtype, q: q})
+		}
+	}
+	return ranges
+}
+
+// quality returns the q-value of the most specific range matching mediaType,
+// or -1 if no range matches.
+func quality(ranges []acceptRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(strings.ToLower(mediaType), "/")
+	q, specificity := -1.0, -1
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// negotiate picks the renderer with the highest client quality,
+// breaking ties by server preference order.
+func negotiate(accept []string, renderers []MediaRenderer) (MediaRenderer, bool) {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		if len(renderers) == 0 {
+			return MediaRenderer{}, false
+		}
+		return renderers[0], true
+	}
+
+	var best MediaRenderer
+	bestQ := 0.0
+	for _, mr := range renderers {
+		if q := quality(ranges, mr.MediaType); q > bestQ {
+			best, bestQ = mr, q
+		}
+	}
+	return best, bestQ > 0
+}
@@ -19,16 +19,26 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
-	"github.com/apstndb/spannerplan"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
 	"github.com/jessevdk/go-flags"
 
+	"github.com/apstndb/spannerplanviz/advisor"
+	"github.com/apstndb/spannerplanviz/fetch"
 	"github.com/apstndb/spannerplanviz/graphviz"
+	"github.com/apstndb/spannerplanviz/htmlpage"
 	"github.com/apstndb/spannerplanviz/mermaid"
 	"github.com/apstndb/spannerplanviz/option"
+	"github.com/apstndb/spannerplanviz/planinput"
+	"github.com/apstndb/spannerplanviz/queryprofiles"
+	"github.com/apstndb/spannerplanviz/schema"
 	"github.com/apstndb/spannerplanviz/visualize"
 )
 
@@ -39,8 +49,12 @@ func main() {
 }
 
 func run(ctx context.Context) error {
+	if len(os.Args) > 1 && os.Args[1] == "advise" {
+		return runAdvise(ctx, os.Args[2:])
+	}
+
 	var opts option.Options
-	p := flags.NewParser(&opts, flags.Default)
+	p := flags.NewParser(&opts, flags.Default|flags.AllowBoolValues)
 	args, err := p.Parse()
 	if err != nil {
 		return err
@@ -51,15 +65,192 @@ func run(ctx context.Context) error {
 		os.Exit(1)
 	}
 
+	if err := option.ApplyConfig(p, opts.ConfigFile); err != nil {
+		return err
+	}
 	if err := opts.Normalize(); err != nil {
 		return err
 	}
 
+	inputs, err := loadPlans(ctx, p, opts.InputOptions)
+	if err != nil {
+		return err
+	}
+
+	if opts.TypeFlag == "html" {
+		return writeOutput(opts.Filename, func(w io.Writer) error {
+			return renderHTML(ctx, w, inputs, opts)
+		})
+	}
+
+	if len(inputs) == 1 {
+		return renderPlan(ctx, opts.Filename, inputs[0], opts)
+	}
+	if opts.Filename == "" {
+		return fmt.Errorf("input contains %d plans: use --output to write one file per plan, --type=html to combine them, or --fingerprint to select one", len(inputs))
+	}
+	for i, input := range inputs {
+		filename := indexedFilename(opts.Filename, i)
+		if err := renderPlan(ctx, filename, input, opts); err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s\n", filename, input.description)
+	}
+	return nil
+}
+
+// planInput is a single plan to be rendered.
+type planInput struct {
+	queryStats *sppb.ResultSetStats
+	rowType    *sppb.StructType
+	// description identifies the plan when the input contains several plans.
+	description string
+	// fingerprint is the TEXT_FINGERPRINT of a sampled query profile.
+	fingerprint string
+	// schema is the database schema loaded from --ddl, or nil.
+	schema *schema.Schema
+}
+
+// indexedFilename inserts "-<i>" before the extension of filename, keeping a compression extension last.
+func indexedFilename(filename string, i int) string {
+	base := planinput.TrimCompressionExt(filename)
+	compressionExt := strings.TrimPrefix(filename, base)
+	ext := filepath.Ext(base)
+	return fmt.Sprintf("%s-%d%s%s", strings.TrimSuffix(base, ext), i, ext, compressionExt)
+}
+
+// writeOutput calls write with filename opened for writing, or with stdout if filename is empty.
+// Output is compressed when filename ends in .gz or .zst.
+// A partially written file is removed when write fails.
+func writeOutput(filename string, write func(io.Writer) error) error {
+	if filename == "" {
+		return write(os.Stdout)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = func() error {
+		w, err := planinput.NewWriter(file, planinput.CompressionFromFilename(filename))
+		if err != nil {
+			return err
+		}
+		if err := write(w); err != nil {
+			_ = w.Close()
+			return err
+		}
+		return w.Close()
+	}()
+	if err != nil {
+		_ = file.Close()
+		if innerErr := os.Remove(filename); innerErr != nil && !os.IsNotExist(innerErr) {
+			return errors.Join(err, innerErr)
+		}
+		return err
+	}
+	return file.Close()
+}
+
+// renderPlan builds and renders input to filename, or to stdout if filename is empty.
+func renderPlan(ctx context.Context, filename string, input planInput, opts option.Options) error {
+	return writeOutput(filename, func(w io.Writer) error {
+		plan, err := buildPlan(input, opts)
+		if err != nil {
+			return err
+		}
+		return render(ctx, w, plan, opts)
+	})
+}
+
+// buildPlan builds input for rendering, highlighting advisor findings and the critical path when requested.
+func buildPlan(input planInput, opts option.Options) (*visualize.Plan, error) {
+	buildOpts := opts.BuildOptions()
+	if input.schema != nil {
+		buildOpts.Schema = input.schema
+	}
+	plan, err := visualize.BuildPlan(input.rowType, input.queryStats, buildOpts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Advise {
+		advisor.Annotate(plan, advisor.Analyze(plan, input.adviseConfig()))
+	}
+	if opts.CriticalPath {
+		printCriticalPath(os.Stderr, input.description, plan.HighlightCriticalPath(), plan.Build.DurationUnit)
+	}
+	return plan, nil
+}
+
+func printCriticalPath(w io.Writer, description string, path []*visualize.TreeNode, durationUnit string) {
+	header := "critical path"
+	if description != "" {
+		header += " (" + description + ")"
+	}
+	if len(path) == 0 {
+		fmt.Fprintf(w, "%s: no latency stats in plan\n", header)
+		return
+	}
+	fmt.Fprintf(w, "%s:\n%s", header, visualize.FormatCriticalPath(path, durationUnit))
+}
+
+// renderHTML renders all inputs into a single HTML page.
+func renderHTML(ctx context.Context, w io.Writer, inputs []planInput, opts option.Options) error {
+	sections := make([]htmlpage.Section, 0, len(inputs))
+	for i, input := range inputs {
+		plan, err := buildPlan(input, opts)
+		if err != nil {
+			return fmt.Errorf("plan %d: %w", i, err)
+		}
+		sections = append(sections, htmlpage.Section{Title: input.description, Plan: plan})
+	}
+	return htmlpage.Write(ctx, w, sections, htmlpage.Options{
+		ShowQuery:      opts.ShowQuery,
+		ShowQueryStats: opts.ShowQueryStats,
+	})
+}
+
+// loadPlans loads the plans to process together with the schema given by --ddl.
+func loadPlans(ctx context.Context, p *flags.Parser, opts option.InputOptions) ([]planInput, error) {
+	var sch *schema.Schema
+	if opts.DDL != "" {
+		s, err := schema.Load(opts.DDL)
+		if err != nil {
+			return nil, err
+		}
+		sch = s
+	}
+
+	inputs, err := readPlans(ctx, p, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range inputs {
+		inputs[i].schema = sch
+	}
+	return inputs, nil
+}
+
+// readPlans fetches the plan from Cloud Spanner when --sql is set, and reads plans from the input otherwise.
+func readPlans(ctx context.Context, p *flags.Parser, opts option.InputOptions) ([]planInput, error) {
+	req, ok, err := opts.FetchRequest()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		queryStats, rowType, err := fetch.Query(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return []planInput{{queryStats: queryStats, rowType: rowType}}, nil
+	}
+
 	var input io.ReadCloser
 	if opts.Positional.Input != "" {
-		file, err := os.Open(opts.Positional.Input)
+		file, err := planinput.Open(opts.Positional.Input)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		input = file
 	} else {
@@ -68,7 +259,11 @@ func run(ctx context.Context) error {
 			p.WriteHelp(os.Stderr)
 			os.Exit(1)
 		}
-		input = os.Stdin
+		r, err := planinput.NewReader(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		input = r
 	}
 	defer func() {
 		_ = input.Close()
@@ -76,54 +271,83 @@ func run(ctx context.Context) error {
 
 	b, err := io.ReadAll(input)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	queryStats, rowType, err := spannerplan.ExtractQueryPlan(b)
+	format, err := planinput.ParseFormat(opts.InputFormat)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var writer io.WriteCloser
-	needsClose := false
-	if opts.Filename == "" {
-		writer = os.Stdout
-	} else if file, err := os.Create(opts.Filename); err != nil {
-		return err
-	} else {
-		writer = file
-		needsClose = true
+	docs := planinput.Documents(b, format)
+	if len(docs) == 0 {
+		// Let the parser report the error for empty input.
+		docs = [][]byte{b}
 	}
-	defer func() {
-		if needsClose {
-			_ = writer.Close()
-		}
-	}()
 
-	plan, err := visualize.BuildPlan(rowType, queryStats, opts.BuildOptions())
-	if err != nil {
-		if opts.Filename != "" && needsClose {
-			needsClose = false
-			_ = writer.Close()
-			if innerErr := os.Remove(opts.Filename); innerErr != nil && !os.IsNotExist(innerErr) {
-				return errors.Join(err, innerErr)
+	var inputs []planInput
+	for i, doc := range docs {
+		plans, err := extractPlans(doc, format)
+		if err != nil {
+			if len(docs) > 1 {
+				return nil, fmt.Errorf("document %d: %w", i, err)
 			}
+			return nil, err
 		}
-		return err
+		if len(docs) > 1 {
+			for j := range plans {
+				plans[j].description = joinNonEmpty(": ", fmt.Sprintf("document %d", i), plans[j].description)
+			}
+		}
+		inputs = append(inputs, plans...)
 	}
 
-	err = render(ctx, writer, plan, opts)
-	if err != nil {
-		if opts.Filename != "" {
-			needsClose = false
-			_ = writer.Close()
-			if innerErr := os.Remove(opts.Filename); innerErr != nil && !os.IsNotExist(innerErr) {
-				return errors.Join(err, innerErr)
-			}
+	if opts.Fingerprint != "" {
+		inputs = slices.DeleteFunc(inputs, func(input planInput) bool {
+			return input.fingerprint != opts.Fingerprint
+		})
+		if len(inputs) == 0 {
+			return nil, fmt.Errorf("no sampled query profile with TEXT_FINGERPRINT %s found in input", opts.Fingerprint)
 		}
-		return err
 	}
-	return nil
+	return inputs, nil
+}
+
+// extractPlans parses a single plan, or the sampled profiles of a SPANNER_SYS.QUERY_PROFILES_TOP_* export.
+func extractPlans(b []byte, format planinput.Format) ([]planInput, error) {
+	queryStats, rowType, err := planinput.Extract(b, format)
+	if err == nil {
+		return []planInput{{queryStats: queryStats, rowType: rowType}}, nil
+	}
+	if !format.IsText() {
+		return nil, err
+	}
+
+	profiles, profilesErr := queryprofiles.Parse(b)
+	if errors.Is(profilesErr, queryprofiles.ErrNotExport) {
+		return nil, err
+	}
+	if profilesErr != nil {
+		return nil, profilesErr
+	}
+	if len(profiles) == 0 {
+		return nil, errors.New("no sampled query profiles found in input")
+	}
+
+	inputs := make([]planInput, 0, len(profiles))
+	for _, profile := range profiles {
+		inputs = append(inputs, planInput{
+			queryStats:  profile.Stats,
+			rowType:     profile.RowType,
+			description: profile.Description(),
+			fingerprint: profile.TextFingerprint,
+		})
+	}
+	return inputs, nil
+}
+
+func joinNonEmpty(sep string, values ...string) string {
+	return strings.Join(slices.DeleteFunc(values, func(s string) bool { return s == "" }), sep)
 }
 
 func render(ctx context.Context, w io.Writer, plan *visualize.Plan, opts option.Options) error {
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestRun_renderErrorStdout(t *testing.T) {
@@ -30,6 +35,294 @@ func TestRun_renderErrorRemovesPartialFile(t *testing.T) {
 	}
 }
 
+const queryProfilesExport = `{
+  "metadata": {"rowType": {"fields": [
+    {"name": "INTERVAL_END", "type": {"code": "TIMESTAMP"}},
+    {"name": "TEXT_FINGERPRINT", "type": {"code": "INT64"}},
+    {"name": "QUERY_PROFILE", "type": {"code": "JSON"}}
+  ]}},
+  "rows": [
+    ["2024-01-01T00:00:00Z", "1", "{\"queryPlan\": {\"planNodes\": [{\"index\": 0, \"kind\": \"RELATIONAL\", \"displayName\": \"Scan\"}]}}"],
+    ["2024-01-01T00:01:00Z", "2", "{\"queryPlan\": {\"planNodes\": [{\"index\": 0, \"kind\": \"RELATIONAL\", \"displayName\": \"Distributed Union\"}]}}"]
+  ]
+}`
+
+func TestRun_queryProfilesExport(t *testing.T) {
+	t.Run("one file per profile", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "plan.dot")
+		if err := runWithInput(t, queryProfilesExport, []string{"--type", "dot", "--output", out}); err != nil {
+			t.Fatalf("run() error = %v", err)
+		}
+		for i, want := range []string{"Scan", "Distributed Union"} {
+			b, err := os.ReadFile(indexedFilename(out, i))
+			if err != nil {
+				t.Fatalf("read output %d: %v", i, err)
+			}
+			if !strings.Contains(string(b), want) {
+				t.Errorf("output %d does not contain %q", i, want)
+			}
+		}
+	})
+
+	t.Run("select by fingerprint", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "plan.dot")
+		if err := runWithInput(t, queryProfilesExport, []string{"--type", "dot", "--fingerprint", "2", "--output", out}); err != nil {
+			t.Fatalf("run() error = %v", err)
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("read output: %v", err)
+		}
+		if !strings.Contains(string(b), "Distributed Union") {
+			t.Errorf("output does not contain the selected profile")
+		}
+	})
+
+	t.Run("multiple plans require --output", func(t *testing.T) {
+		if err := runWithInput(t, queryProfilesExport, []string{"--type", "dot"}); err == nil {
+			t.Fatal("run() error = nil, want error for multiple plans on stdout")
+		}
+	})
+
+	t.Run("unknown fingerprint", func(t *testing.T) {
+		if err := runWithInput(t, queryProfilesExport, []string{"--type", "dot", "--fingerprint", "3"}); err == nil {
+			t.Fatal("run() error = nil, want no profiles error")
+		}
+	})
+}
+
+func TestRun_multiDocument(t *testing.T) {
+	jsonLines := `{"queryPlan": {"planNodes": [{"index": 0, "kind": "RELATIONAL", "displayName": "Scan"}]}}
+{"stats": {"queryPlan": {"planNodes": [{"index": 0, "kind": "RELATIONAL", "displayName": "Distributed Union"}]}}}
+`
+	yamlStream := `queryPlan:
+  planNodes:
+    - {index: 0, kind: RELATIONAL, displayName: Scan}
+---
+planNodes:
+  - {index: 0, kind: RELATIONAL, displayName: Distributed Union}
+`
+
+	t.Run("JSON Lines to indexed files", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "plan.dot")
+		if err := runWithInput(t, jsonLines, []string{"--type", "dot", "--output", out}); err != nil {
+			t.Fatalf("run() error = %v", err)
+		}
+		for i, want := range []string{"Scan", "Distributed Union"} {
+			b, err := os.ReadFile(indexedFilename(out, i))
+			if err != nil {
+				t.Fatalf("read output %d: %v", i, err)
+			}
+			if !strings.Contains(string(b), want) {
+				t.Errorf("output %d does not contain %q", i, want)
+			}
+		}
+	})
+
+	t.Run("YAML stream to HTML page", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "plans.html")
+		if err := runWithInput(t, yamlStream, []string{"--type", "html", "--output", out}); err != nil {
+			t.Fatalf("run() error = %v", err)
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("read output: %v", err)
+		}
+		page := string(b)
+		for _, want := range []string{"document 0", "document 1", "Scan", "Distributed Union"} {
+			if !strings.Contains(page, want) {
+				t.Errorf("page does not contain %q", want)
+			}
+		}
+	})
+
+	t.Run("invalid document is reported with its index", func(t *testing.T) {
+		err := runWithInput(t, jsonLines+`{"unknown": 1}`+"\n", []string{"--type", "dot", "--output", filepath.Join(t.TempDir(), "plan.dot")})
+		if err == nil || !strings.Contains(err.Error(), "document 2") {
+			t.Fatalf("run() error = %v, want error for document 2", err)
+		}
+	})
+}
+
+func TestRun_protobufInput(t *testing.T) {
+	b, err := proto.Marshal(&sppb.QueryPlan{PlanNodes: []*sppb.PlanNode{
+		{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Scan"},
+	}})
+	if err != nil {
+		t.Fatalf("proto.Marshal() error = %v", err)
+	}
+
+	for _, format := range []string{"auto", "protobuf"} {
+		t.Run(format, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "plan.dot")
+			if err := runWithInput(t, string(b), []string{"--type", "dot", "--input-format", format, "--output", out}); err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatalf("read output: %v", err)
+			}
+			if !strings.Contains(string(got), "Scan") {
+				t.Errorf("output does not contain Scan")
+			}
+		})
+	}
+
+	t.Run("explicit format mismatch", func(t *testing.T) {
+		if err := runWithInput(t, string(b), []string{"--type", "dot", "--input-format", "json"}); err == nil {
+			t.Fatal("run() error = nil, want JSON parse error")
+		}
+	})
+}
+
+func TestRun_compressed(t *testing.T) {
+	var input bytes.Buffer
+	zw := gzip.NewWriter(&input)
+	_, _ = io.WriteString(zw, `{"queryPlan": {"planNodes": [{"index": 0, "kind": "RELATIONAL", "displayName": "Scan"}]}}`)
+	_ = zw.Close()
+
+	out := filepath.Join(t.TempDir(), "plan.dot.gz")
+	if err := runWithInput(t, input.String(), []string{"--type", "dot", "--output", out}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatalf("open output: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("output is not gzip: %v", err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if !strings.Contains(string(got), "Scan") {
+		t.Errorf("output does not contain Scan")
+	}
+}
+
+func TestRun_advise(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("visualize", "testdata", "dca_profile.json"))
+	if err != nil {
+		t.Fatalf("read dca_profile.json: %v", err)
+	}
+
+	t.Run("text", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "findings.txt")
+		if err := runWithInput(t, string(input), []string{"advise", "--output", out}); err != nil {
+			t.Fatalf("run() error = %v", err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("read output: %v", err)
+		}
+		want := "warning node5 (Table Scan): full-table-scan: Table Scan scans Singers without a seek condition (1000 rows scanned)\n" +
+			"warning node28 (Filter Scan): unselective-residual-filter: residual condition discards 99.7% of 1024000 scanned rows; consider an index that covers the condition\n"
+		if string(got) != want {
+			t.Errorf("output = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("json with min severity", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "findings.json")
+		if err := runWithInput(t, string(input), []string{"advise", "--format", "json", "--min-severity", "critical", "--output", out}); err != nil {
+			t.Fatalf("run() error = %v", err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("read output: %v", err)
+		}
+		if strings.TrimSpace(string(got)) != "[]" {
+			t.Errorf("output = %q, want []", got)
+		}
+	})
+
+	t.Run("overlay", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "plan.mmd")
+		if err := runWithInput(t, string(input), []string{"--type", "mermaid", "--advise", "--output", out}); err != nil {
+			t.Fatalf("run() error = %v", err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("read output: %v", err)
+		}
+		for _, want := range []string{"style node5 stroke:orange", "style node28 stroke:orange"} {
+			if !strings.Contains(string(got), want) {
+				t.Errorf("output does not contain %q", want)
+			}
+		}
+	})
+}
+
+func TestRun_ddl(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("visualize", "testdata", "various_characters_profile.json"))
+	if err != nil {
+		t.Fatalf("read various_characters_profile.json: %v", err)
+	}
+	ddl := filepath.Join(t.TempDir(), "schema.sql")
+	if err := os.WriteFile(ddl, []byte(`
+CREATE TABLE Singers (SingerId INT64 NOT NULL, FirstName STRING(1024), LastName STRING(1024)) PRIMARY KEY (SingerId);
+CREATE INDEX SingersByFirstLastName ON Singers(FirstName, LastName);
+`), 0o644); err != nil {
+		t.Fatalf("write DDL: %v", err)
+	}
+
+	t.Run("scan annotation", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "plan.mmd")
+		if err := runWithInput(t, string(input), []string{"--type", "mermaid", "--ddl", ddl, "--output", out}); err != nil {
+			t.Fatalf("run() error = %v", err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("read output: %v", err)
+		}
+		for _, want := range []string{"Index&nbsp;key:&nbsp;FirstName,&nbsp;LastName", "Primary&nbsp;key:&nbsp;SingerId"} {
+			if !strings.Contains(string(got), want) {
+				t.Errorf("output does not contain %q", want)
+			}
+		}
+	})
+
+	t.Run("index back join", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "findings.txt")
+		if err := runWithInput(t, string(input), []string{"advise", "--ddl", ddl, "--rule", "index-back-join", "--output", out}); err != nil {
+			t.Fatalf("run() error = %v", err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("read output: %v", err)
+		}
+		if !strings.Contains(string(got), "node6 (Index Scan): index-back-join:") {
+			t.Errorf("output = %q, want an index-back-join finding for node6", got)
+		}
+	})
+
+	t.Run("invalid DDL", func(t *testing.T) {
+		bad := filepath.Join(t.TempDir(), "bad.sql")
+		if err := os.WriteFile(bad, []byte("CREATE TABLE ("), 0o644); err != nil {
+			t.Fatalf("write DDL: %v", err)
+		}
+		if err := runWithInput(t, string(input), []string{"--type", "dot", "--ddl", bad}); err == nil {
+			t.Fatal("run() error = nil, want DDL parse error")
+		}
+	})
+}
+
+func TestIndexedFilename(t *testing.T) {
+	for in, want := range map[string]string{
+		"plan.svg":    "plan-1.svg",
+		"plan.svg.gz": "plan-1.svg.gz",
+		"plan":        "plan-1",
+	} {
+		if got := indexedFilename(in, 1); got != want {
+			t.Errorf("indexedFilename(%q, 1) = %q, want %q", in, got, want)
+		}
+	}
+}
+
 func runWithInput(t *testing.T, input string, extraArgs []string) error {
 	t.Helper()
 
@@ -79,6 +79,9 @@ func writeMermaid(writer io.Writer, plan *visualize.Plan, build visualize.BuildO
 
 	renderedNodes := make(map[string]bool)
 	var edgesToRender []string
+	// linkStyles are the linkStyle declarations of edgesToRender by index. Mermaid keeps only
+	// the last linkStyle of an edge, so each edge gets a single combined declaration.
+	linkStyles := make(map[int][]string)
 
 	styleTranslation := map[visualize.EdgeStyle]string{
 		visualize.EdgeStyleSolid:  "-->",
@@ -86,8 +89,29 @@ func writeMermaid(writer io.Writer, plan *visualize.Plan, build visualize.BuildO
 		visualize.EdgeStyleDotted: "-.->",
 	}
 
-	var walk func(*visualize.TreeNode)
-	walk = func(node *visualize.TreeNode) {
+	addEdge := func(nodeName string, edgeLink *visualize.Link) {
+		arrow, ok := styleTranslation[edgeLink.Style]
+		if !ok {
+			arrow = "-->"
+		}
+
+		var edgeLabelPart string
+		if edgeLink.ChildType != "" {
+			edgeLabelPart = fmt.Sprintf("|%s|", escapeMermaidEdgeLabel(edgeLink.ChildType))
+		}
+		edgeStr := fmt.Sprintf("    %s %s%s %s\n", nodeName, arrow, edgeLabelPart, edgeLink.ChildNode.GetName())
+		// Dotted links share the arrow of dashed remote calls; a finer dash tells them apart.
+		if edgeLink.Style == visualize.EdgeStyleDotted {
+			linkStyles[len(edgesToRender)] = append(linkStyles[len(edgesToRender)], "stroke-dasharray:2 2")
+		}
+		if edgeLink.Highlighted {
+			linkStyles[len(edgesToRender)] = append(linkStyles[len(edgesToRender)], "stroke:red,stroke-width:3px")
+		}
+		edgesToRender = append(edgesToRender, edgeStr)
+	}
+
+	var walk func(node *visualize.TreeNode, expression bool)
+	walk = func(node *visualize.TreeNode, expression bool) {
 		if node == nil {
 			return
 		}
@@ -99,31 +123,53 @@ func writeMermaid(writer io.Writer, plan *visualize.Plan, build visualize.BuildO
 
 		finalLabel := node.MermaidLabel(build, plan.RowType)
 
-		fmt.Fprintf(&sb, "    %s[\"%s\"]\n", nodeName, finalLabel)
+		if expression {
+			fmt.Fprintf(&sb, "    %s(\"%s\")\n", nodeName, finalLabel)
+		} else {
+			fmt.Fprintf(&sb, "    %s[\"%s\"]\n", nodeName, finalLabel)
+		}
 		fmt.Fprintf(&sb, "    style %s text-align:left;\n", nodeName)
+		if color := node.OutlineColor(); color != "" {
+			fmt.Fprintf(&sb, "    style %s stroke:%s,stroke-width:2px\n", nodeName, color)
+		}
+		if node.OmittedOperators() > 0 {
+			fmt.Fprintf(&sb, "    style %s stroke-dasharray:5 5\n", nodeName)
+		}
+		if node.IsHighlighted() {
+			fmt.Fprintf(&sb, "    style %s fill:%s\n", nodeName, visualize.HighlightColor)
+		}
+		if truncated := node.TruncatedText(build, plan.RowType); truncated != "" {
+			// Mermaid only attaches tooltips with click. A link to "#" needs no JavaScript
+			// callback and is allowed by the default strict securityLevel.
+			fmt.Fprintf(&sb, "    click %s href \"#\" \"%s\"\n", nodeName, escapeMermaidTooltip(truncated))
+		}
 
 		for _, edgeLink := range node.Children {
-			arrow, ok := styleTranslation[edgeLink.Style]
-			if !ok {
-				arrow = "-->"
-			}
-
-			var edgeLabelPart string
-			if edgeLink.ChildType != "" {
-				edgeLabelPart = fmt.Sprintf("|%s|", escapeMermaidEdgeLabel(edgeLink.ChildType))
-			}
-			edgeStr := fmt.Sprintf("    %s %s%s %s\n", nodeName, arrow, edgeLabelPart, edgeLink.ChildNode.GetName())
-			edgesToRender = append(edgesToRender, edgeStr)
-
-			walk(edgeLink.ChildNode)
+			addEdge(nodeName, edgeLink)
+			walk(edgeLink.ChildNode, false)
+		}
+		for _, edgeLink := range node.ExpressionLinks() {
+			addEdge(nodeName, edgeLink)
+			walk(edgeLink.ChildNode, true)
 		}
 	}
 
-	walk(plan.Root)
+	walk(plan.Root, false)
+
+	for _, flow := range plan.VariableFlows {
+		linkStyles[len(edgesToRender)] = []string{fmt.Sprintf("stroke:%s,color:%s,stroke-dasharray:6 3", visualize.VariableFlowColor, visualize.VariableFlowColor)}
+		edgesToRender = append(edgesToRender, fmt.Sprintf("    %s -.->|%s| %s\n",
+			flow.From.GetName(), escapeMermaidEdgeLabel(strings.Join(flow.Variables, ", ")), flow.To.GetName()))
+	}
 
 	for _, edgeStr := range edgesToRender {
 		sb.WriteString(edgeStr)
 	}
+	for i := range edgesToRender {
+		if styles, ok := linkStyles[i]; ok {
+			fmt.Fprintf(&sb, "    linkStyle %d %s\n", i, strings.Join(styles, ","))
+		}
+	}
 
 	_, err = writer.Write([]byte(sb.String()))
 	return err
@@ -138,6 +184,19 @@ var mermaidEdgeLabelReplacer = strings.NewReplacer(
 	"<", "#60;",
 )
 
+var mermaidTooltipReplacer = strings.NewReplacer(
+	"\n", "<br>",
+	"\r", "",
+	`"`, "#quot;",
+	"<", "#lt;",
+	">", "#gt;",
+)
+
+// escapeMermaidTooltip prepares text for the quoted tooltip of a click statement.
+func escapeMermaidTooltip(text string) string {
+	return mermaidTooltipReplacer.Replace(text)
+}
+
 // escapeMermaidEdgeLabel prepares text for Mermaid flowchart edge labels (-->|label|).
 func escapeMermaidEdgeLabel(label string) string {
 	return mermaidEdgeLabelReplacer.Replace(label)
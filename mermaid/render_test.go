@@ -5,6 +5,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -248,3 +249,158 @@ func TestSourceWithOptions_canDisableMetadataFromFullPlan(t *testing.T) {
 		t.Fatalf("SourceWithOptions() output = %q, want metadata disabled", src)
 	}
 }
+
+func TestSource_features(t *testing.T) {
+	t.Parallel()
+
+	unionAll := []*sppb.PlanNode{
+		{Index: 0, DisplayName: "Union All", Kind: sppb.PlanNode_RELATIONAL, ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 1}, {ChildIndex: 2}}},
+		{Index: 1, DisplayName: "Scan", Kind: sppb.PlanNode_RELATIONAL},
+		{Index: 2, DisplayName: "Scan", Kind: sppb.PlanNode_RELATIONAL},
+	}
+	filter := []*sppb.PlanNode{
+		{Index: 0, DisplayName: "Filter", Kind: sppb.PlanNode_RELATIONAL, ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 1}, {ChildIndex: 2, Type: "Condition"}}},
+		{Index: 1, DisplayName: "Scan", Kind: sppb.PlanNode_RELATIONAL},
+		{Index: 2, DisplayName: "Function", Kind: sppb.PlanNode_SCALAR, ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 3}}, ShortRepresentation: &sppb.PlanNode_ShortRepresentation{Description: "NOT($x)"}},
+		{Index: 3, DisplayName: "Reference", Kind: sppb.PlanNode_SCALAR, ShortRepresentation: &sppb.PlanNode_ShortRepresentation{Description: "$x"}},
+	}
+
+	for _, tt := range []struct {
+		desc  string
+		nodes []*sppb.PlanNode
+		opts  visualize.BuildOptions
+		edit  func(plan *visualize.Plan)
+		// want are statements of the source, and absent are prefixes no statement may have.
+		want   []string
+		absent []string
+	}{
+		{
+			desc:  "annotations",
+			nodes: unionAll,
+			edit: func(plan *visualize.Plan) {
+				plan.Root.AddAnnotation(visualize.Annotation{Text: "spills", Color: "red"})
+			},
+			want:   []string{"node0[\"<b>Union&nbsp;All</b>\n<b>spills</b>\"]", "style node0 stroke:red,stroke-width:2px"},
+			absent: []string{"style node1 stroke:"},
+		},
+		{
+			desc:  "highlighted links",
+			nodes: unionAll,
+			edit: func(plan *visualize.Plan) {
+				plan.Root.Children[1].Highlighted = true
+			},
+			want:   []string{"node0 --> node2", "linkStyle 1 stroke:red,stroke-width:3px"},
+			absent: []string{"linkStyle 0 "},
+		},
+		{
+			desc:   "highlighted nodes",
+			nodes:  unionAll,
+			opts:   visualize.BuildOptions{Highlight: `index == 1`},
+			want:   []string{"style node1 fill:lightyellow"},
+			absent: []string{"style node0 fill:", "style node2 fill:"},
+		},
+		{
+			desc:  "placeholders",
+			nodes: unionAll,
+			opts:  visualize.BuildOptions{MaxDepth: 1},
+			want: []string{
+				`node1_omitted["<b>…&nbsp;1&nbsp;more&nbsp;operator</b>"]`,
+				"style node1_omitted stroke-dasharray:5 5",
+				"node0 --> node1_omitted",
+				"node0 --> node2_omitted",
+			},
+			absent: []string{"node1[", "style node0 stroke-dasharray:"},
+		},
+		{
+			desc: "truncated text",
+			nodes: []*sppb.PlanNode{
+				{Index: 0, DisplayName: "Filter", Kind: sppb.PlanNode_RELATIONAL, ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 1, Type: "Condition"}}},
+				{Index: 1, DisplayName: "Function", Kind: sppb.PlanNode_SCALAR, ShortRepresentation: &sppb.PlanNode_ShortRepresentation{Description: `($a = 1) AND ("b" < $c)`}},
+			},
+			opts: visualize.BuildOptions{NonVariableScalar: true, WrapWidth: 16, MaxLines: 1},
+			want: []string{`click node0 href "#" "Condition: ($a = 1) AND (#quot;b#quot; #lt; $c)"`},
+		},
+		{
+			desc:  "scalar expressions",
+			nodes: filter,
+			opts:  visualize.BuildOptions{ScalarExpressions: true},
+			want: []string{
+				`node1["<b>Scan</b>"]`,
+				"node2(\"<b>Function</b>\nNOT($x)\")",
+				"node3(\"<b>Reference</b>\n$x\")",
+				"node0 --> node1",
+				"node0 -.->|Condition| node2",
+				"node2 -.-> node3",
+				"linkStyle 1 stroke-dasharray:2 2",
+				"linkStyle 2 stroke-dasharray:2 2",
+			},
+			absent: []string{"linkStyle 0 "},
+		},
+		{
+			desc:  "highlighted scalar expression",
+			nodes: filter,
+			opts:  visualize.BuildOptions{ScalarExpressions: true},
+			edit: func(plan *visualize.Plan) {
+				plan.Root.Expressions[0].Highlighted = true
+			},
+			want: []string{"linkStyle 1 stroke-dasharray:2 2,stroke:red,stroke-width:3px"},
+		},
+		{
+			desc: "variable flows",
+			nodes: []*sppb.PlanNode{
+				{Index: 0, DisplayName: "Cross Apply", Kind: sppb.PlanNode_RELATIONAL, ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 1}, {ChildIndex: 3}}},
+				{Index: 1, DisplayName: "Scan", Kind: sppb.PlanNode_RELATIONAL, ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 2, Variable: "x"}}},
+				{Index: 2, DisplayName: "Reference", Kind: sppb.PlanNode_SCALAR, ShortRepresentation: &sppb.PlanNode_ShortRepresentation{Description: "x"}},
+				{Index: 3, DisplayName: "Filter", Kind: sppb.PlanNode_RELATIONAL, ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 4, Type: "Condition"}}},
+				{Index: 4, DisplayName: "Reference", Kind: sppb.PlanNode_SCALAR, ShortRepresentation: &sppb.PlanNode_ShortRepresentation{Description: "$x"}},
+			},
+			opts:   visualize.BuildOptions{VariableFlows: true},
+			want:   []string{"node1 -.->|$x| node3", "linkStyle 2 stroke:steelblue,color:steelblue,stroke-dasharray:6 3"},
+			absent: []string{"linkStyle 0 ", "linkStyle 1 "},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			plan, err := visualize.BuildPlan(nil, &sppb.ResultSetStats{QueryPlan: &sppb.QueryPlan{PlanNodes: tt.nodes}}, tt.opts)
+			if err != nil {
+				t.Fatalf("BuildPlan() error = %v", err)
+			}
+			if tt.edit != nil {
+				tt.edit(plan)
+			}
+
+			src, err := mermaid.Source(plan)
+			if err != nil {
+				t.Fatalf("Source() error = %v", err)
+			}
+			got := statements(src)
+			for _, want := range tt.want {
+				if !slices.Contains(got, want) {
+					t.Errorf("Source() has no statement %q:\n%s", want, src)
+				}
+			}
+			for _, prefix := range tt.absent {
+				for _, stmt := range got {
+					if strings.HasPrefix(stmt, prefix) {
+						t.Errorf("Source() has statement %q, want none starting with %q", stmt, prefix)
+					}
+				}
+			}
+		})
+	}
+}
+
+// statements splits Mermaid source into its indented statements, keeping the lines of
+// multi-line labels together.
+func statements(src string) []string {
+	var result []string
+	for _, line := range strings.Split(strings.TrimSuffix(src, "\n"), "\n") {
+		if stmt, ok := strings.CutPrefix(line, "    "); ok || len(result) == 0 {
+			result = append(result, stmt)
+			continue
+		}
+		result[len(result)-1] += "\n" + line
+	}
+	return result
+}
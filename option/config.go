@@ -0,0 +1,236 @@
+package option
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/jessevdk/go-flags"
+	"sigs.k8s.io/yaml"
+)
+
+// ConfigFileName is the name of the project config file looked up by FindConfig.
+const ConfigFileName = ".spannerplanviz.yaml"
+
+// Config is a project config file. Both sections map long flag names without the leading
+// dashes to values: booleans, numbers, strings, or lists for repeatable flags.
+//
+//	defaults:
+//	  ddl: schema.sql
+//	  hide-metadata: [execution_method]
+//	presets:
+//	  perf:
+//	    execution-stats: true
+//	    self-time: true
+//
+// The defaults apply to every command that has the flag; the render command selects a preset
+// with --preset, or with a preset key in the defaults.
+//
+// Relative paths given to file flags such as ddl are resolved against the directory of the file.
+type Config struct {
+	Defaults map[string]any            `json:"defaults"`
+	Presets  map[string]map[string]any `json:"presets"`
+
+	// dir is the directory of the config file.
+	dir string
+}
+
+// configPathFlags are the flags whose values are files.
+var configPathFlags = []string{"ddl", "mermaid-label-template", "graphviz-label-template"}
+
+// builtinPresets are the presets available without a config file.
+// They match visualize.FullBuildOptions and visualize.StructureBuildOptions.
+var builtinPresets = map[string]map[string]any{
+	"full":      {"full": true},
+	"structure": {"metadata": true, "serialize-result": true},
+}
+
+// LoadConfig reads and validates the config file filename.
+func LoadConfig(filename string) (*Config, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	var cfg Config
+	if err := yaml.UnmarshalStrict(b, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", filename, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", filename, err)
+	}
+	cfg.dir = filepath.Dir(filename)
+	return &cfg, nil
+}
+
+// FindConfig returns the path of the nearest ConfigFileName in dir or one of its parents,
+// or "" if there is none.
+func FindConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		filename := filepath.Join(dir, ConfigFileName)
+		if _, err := os.Stat(filename); err == nil {
+			return filename, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// validate reports keys that are not flags of any command and values of unsupported types.
+func (c *Config) validate() error {
+	parsers := []*flags.Parser{flags.NewParser(&Options{}, flags.None), flags.NewParser(&AdviseOptions{}, flags.None)}
+	known := func(key string) bool {
+		return slices.ContainsFunc(parsers, func(p *flags.Parser) bool { return p.FindOptionByLongName(key) != nil })
+	}
+
+	check := func(section string, values map[string]any) error {
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			if !known(key) || key == "config" || (key == "preset" && section != "defaults") {
+				return fmt.Errorf("%s: unknown flag %q", section, key)
+			}
+			if _, err := flagValues(values[key]); err != nil {
+				return fmt.Errorf("%s: %s: %w", section, key, err)
+			}
+		}
+		return nil
+	}
+	if err := check("defaults", c.Defaults); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Presets)) {
+		if err := check("presets."+name, c.Presets[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Preset returns the flag values of the named preset. Presets of the config file take
+// precedence over the built-in "full" and "structure" presets. c may be nil.
+func (c *Config) Preset(name string) (map[string]any, error) {
+	if c != nil {
+		if preset, ok := c.Presets[name]; ok {
+			return preset, nil
+		}
+	}
+	if preset, ok := builtinPresets[name]; ok {
+		return preset, nil
+	}
+	return nil, fmt.Errorf("unknown preset %q", name)
+}
+
+// ApplyConfig completes the options parsed by p with the config file filename, or with the
+// nearest ConfigFileName above the working directory if filename is empty.
+// Flags given on the command line take precedence over the selected preset,
+// and the preset over the defaults of the config file. Repeatable flags are replaced, not merged.
+func ApplyConfig(p *flags.Parser, filename string) error {
+	if filename == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		if filename, err = FindConfig(wd); err != nil {
+			return err
+		}
+	}
+
+	var cfg *Config
+	if filename != "" {
+		var err error
+		if cfg, err = LoadConfig(filename); err != nil {
+			return err
+		}
+	}
+	return cfg.Apply(p)
+}
+
+// Apply sets the options of p that were not set on the command line from the defaults and
+// the preset selected by --preset or by the defaults. Defaults for flags that p does not have
+// are skipped, so that one file can serve several commands. c may be nil, in which case
+// only the built-in presets are available.
+func (c *Config) Apply(p *flags.Parser) error {
+	values := make(map[string]any)
+	if c != nil {
+		maps.Copy(values, c.Defaults)
+	}
+
+	if option := p.FindOptionByLongName("preset"); option != nil {
+		name, _ := option.Value().(string)
+		if !setOnCommandLine(option) {
+			name, _ = values["preset"].(string)
+		}
+		delete(values, "preset")
+		if name != "" {
+			preset, err := c.Preset(name)
+			if err != nil {
+				return err
+			}
+			maps.Copy(values, preset)
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		option := p.FindOptionByLongName(key)
+		if option == nil || setOnCommandLine(option) {
+			continue
+		}
+		args, err := flagValues(values[key])
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", key, err)
+		}
+		for _, arg := range args {
+			if c != nil && c.dir != "" && slices.Contains(configPathFlags, key) && !filepath.IsAbs(arg) {
+				arg = filepath.Join(c.dir, arg)
+			}
+			if err := option.Set(&arg); err != nil {
+				return fmt.Errorf("config: %s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setOnCommandLine reports whether option was given as a flag rather than set from its default tag.
+func setOnCommandLine(option *flags.Option) bool {
+	return option.IsSet() && !option.IsSetDefault()
+}
+
+// flagValues converts a config value to the arguments of a flag.
+func flagValues(v any) ([]string, error) {
+	switch v := v.(type) {
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		var args []string
+		for _, elem := range v {
+			if _, ok := elem.([]any); ok {
+				return nil, errors.New("nested lists are not supported")
+			}
+			arg, err := flagValues(elem)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg...)
+		}
+		return args, nil
+	default:
+		return nil, fmt.Errorf("unsupported value %v", v)
+	}
+}
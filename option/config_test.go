@@ -0,0 +1,155 @@
+package option
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jessevdk/go-flags"
+)
+
+const testConfig = `
+defaults:
+  ddl: schema.sql
+  type: mermaid
+  execution-stats: true
+  hide-metadata: [execution_method]
+  min-severity: warning
+presets:
+  perf:
+    self-time: true
+    percent-of-query: true
+  review:
+    metadata: true
+    execution-stats: false
+    hide-metadata: [execution_method, subquery_cluster_node]
+`
+
+func writeConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	filename := filepath.Join(dir, ConfigFileName)
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return filename
+}
+
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := FindConfig(nested); err != nil || got != "" {
+		t.Errorf("FindConfig() = %q, %v, want no config", got, err)
+	}
+	want := writeConfig(t, root, testConfig)
+	if got, err := FindConfig(nested); err != nil || got != want {
+		t.Errorf("FindConfig() = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := LoadConfig(writeConfig(t, dir, testConfig))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	parse := func(t *testing.T, args ...string) Options {
+		t.Helper()
+		var opts Options
+		p := flags.NewParser(&opts, flags.AllowBoolValues)
+		if _, err := p.ParseArgs(args); err != nil {
+			t.Fatalf("ParseArgs() error = %v", err)
+		}
+		if err := cfg.Apply(p); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		return opts
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		opts := parse(t)
+		if opts.TypeFlag != "mermaid" || !opts.ExecutionStats || opts.SelfTime {
+			t.Errorf("defaults not applied: %+v", opts)
+		}
+		if want := filepath.Join(dir, "schema.sql"); opts.DDL != want {
+			t.Errorf("DDL = %q, want %q relative to the config", opts.DDL, want)
+		}
+	})
+
+	t.Run("preset overrides defaults", func(t *testing.T) {
+		opts := parse(t, "--preset", "review")
+		if !opts.Metadata || opts.ExecutionStats {
+			t.Errorf("preset not applied: %+v", opts)
+		}
+		if diff := cmp.Diff([]string{"execution_method", "subquery_cluster_node"}, opts.HideMetadata); diff != "" {
+			t.Errorf("HideMetadata mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("flags override preset and defaults", func(t *testing.T) {
+		opts := parse(t, "--preset=perf", "--type=svg", "--percent-of-query=false", "--hide-metadata=call_type")
+		if opts.TypeFlag != "svg" || opts.PercentOfQuery || !opts.SelfTime {
+			t.Errorf("flags not preferred: %+v", opts)
+		}
+		if diff := cmp.Diff([]string{"call_type"}, opts.HideMetadata); diff != "" {
+			t.Errorf("HideMetadata mismatch (-want +got):\n%s", diff)
+		}
+		if got := opts.BuildOptions(); !got.ExecutionStats || !got.SelfTime || got.PercentOfQuery {
+			t.Errorf("BuildOptions() = %+v", got)
+		}
+	})
+
+	t.Run("built-in preset", func(t *testing.T) {
+		if opts := parse(t, "--preset=full"); !opts.BuildOptions().SerializeResult {
+			t.Errorf("full preset not applied: %+v", opts)
+		}
+	})
+
+	t.Run("unknown preset", func(t *testing.T) {
+		var opts Options
+		p := flags.NewParser(&opts, flags.AllowBoolValues)
+		if _, err := p.ParseArgs([]string{"--preset=fast"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Apply(p); err == nil || !strings.Contains(err.Error(), `unknown preset "fast"`) {
+			t.Errorf("Apply() error = %v, want unknown preset", err)
+		}
+	})
+
+	t.Run("advise skips render flags", func(t *testing.T) {
+		var opts AdviseOptions
+		p := flags.NewParser(&opts, flags.AllowBoolValues)
+		if _, err := p.ParseArgs(nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Apply(p); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		if opts.MinSeverity != "warning" || opts.DDL == "" {
+			t.Errorf("defaults not applied: %+v", opts)
+		}
+	})
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, tt := range []struct {
+		content, want string
+	}{
+		{"defaults:\n  execution-stat: true\n", `defaults: unknown flag "execution-stat"`},
+		{"presets:\n  perf:\n    preset: full\n", `presets.perf: unknown flag "preset"`},
+		{"defaults:\n  config: other.yaml\n", `unknown flag "config"`},
+		{"defaults:\n  hide-metadata: [[a]]\n", "nested lists are not supported"},
+		{"default:\n  full: true\n", "unknown field"},
+	} {
+		_, err := LoadConfig(writeConfig(t, t.TempDir(), tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadConfig(%q) error = %v, want it to contain %q", tt.content, err, tt.want)
+		}
+	}
+}
@@ -2,15 +2,40 @@ package option
 
 import (
 	"fmt"
+	"os"
+	"slices"
 
+	"github.com/apstndb/spannerplanviz/advisor"
+	"github.com/apstndb/spannerplanviz/fetch"
+	"github.com/apstndb/spannerplanviz/planinput"
 	"github.com/apstndb/spannerplanviz/visualize"
 )
 
-type Options struct {
+// InputOptions select where plans are read from. They are shared by all commands.
+type InputOptions struct {
 	Positional struct {
 		Input string
 	} `positional-args:"yes"`
-	TypeFlag          string   `long:"type" description:"output type" default:"svg" choice:"svg" choice:"dot" choice:"png" choice:"mermaid"` // nolint:staticcheck
+	InputFormat string `long:"input-format" description:"input encoding" default:"auto" choice:"auto" choice:"json" choice:"yaml" choice:"protobuf" choice:"prototext"` // nolint:staticcheck
+	Fingerprint string `long:"fingerprint" description:"render only sampled profiles with this TEXT_FINGERPRINT from a SPANNER_SYS.QUERY_PROFILES_TOP_* export"`
+
+	Project  string   `long:"project" description:"Cloud Spanner project ID used with --sql"`
+	Instance string   `long:"instance" description:"Cloud Spanner instance ID used with --sql"`
+	Database string   `long:"database" description:"Cloud Spanner database ID used with --sql"`
+	SQL      string   `long:"sql" description:"run the query on Cloud Spanner and visualize its plan instead of reading input (honours SPANNER_EMULATOR_HOST)"`
+	Mode     string   `long:"mode" description:"query mode used with --sql" default:"plan" choice:"plan" choice:"profile"` // nolint:staticcheck
+	Params   []string `long:"param" description:"query parameter used with --sql as name[:TYPE]=value (repeatable)"`
+
+	DDL string `long:"ddl" description:"DDL file with the CREATE TABLE and CREATE INDEX statements of the database, used to annotate scans and check index back joins"`
+}
+
+type Options struct {
+	InputOptions
+
+	ConfigFile string `long:"config" description:"config file with default flags and presets (default: the nearest .spannerplanviz.yaml in the working directory or its parents)"`
+	Preset     string `long:"preset" description:"apply a preset of the config file, or the built-in full or structure preset; flags given on the command line take precedence"`
+
+	TypeFlag          string   `long:"type" description:"output type" default:"svg" choice:"svg" choice:"dot" choice:"png" choice:"mermaid" choice:"html"` // nolint:staticcheck
 	Filename          string   `long:"output"`
 	NonVariableScalar bool     `long:"non-variable-scalar"`
 	VariableScalar    bool     `long:"variable-scalar"`
@@ -22,7 +47,45 @@ type Options struct {
 	ShowQuery         bool     `long:"show-query"`
 	ShowQueryStats    bool     `long:"show-query-stats"`
 	Full              bool     `long:"full" description:"full output"`
-	HideMetadata      []string `long:"hide-metadata"`
+	HideMetadata      []string `long:"hide-metadata" description:"hide metadata keys matching this glob or /regexp/ (repeatable)"`
+	ShowMetadata      []string `long:"show-metadata" description:"show only metadata keys matching this glob or /regexp/ (repeatable)"`
+	HideStats         []string `long:"hide-stat" description:"hide execution stats matching this glob or /regexp/, e.g. 'execution_summary.*' (repeatable)"`
+	ShowStats         []string `long:"show-stat" description:"show only execution stats matching this glob or /regexp/ (repeatable)"`
+	SelfTime          bool     `long:"self-time" description:"show exclusive latency and cpu_time of each operator with --execution-stats"`
+	PercentOfQuery    bool     `long:"percent-of-query" description:"show the share of query elapsed_time and cpu_time next to latency and cpu_time stats"`
+	DurationUnit      string   `long:"duration-unit" description:"show all time stats in this unit" choice:"usecs" choice:"msecs" choice:"secs" choice:"mins"` // nolint:staticcheck
+	Advise            bool     `long:"advise" description:"highlight nodes with plan advisor findings"`
+	CriticalPath      bool     `long:"critical-path" description:"highlight the latency critical path and print it to stderr"`
+	RootNode          int32    `long:"root-node" description:"render only the subtree below the operator with this plan node index"`
+	MaxDepth          int      `long:"max-depth" description:"render only this many levels of operators and summarize the rest"`
+	HideOperators     []string `long:"hide-operator" description:"hide operators whose name matches this glob pattern and link their children to the parent (repeatable)"`
+	Compact           bool     `long:"compact" description:"merge chains of single-child operators into one box"`
+	ScalarExpressions bool     `long:"scalar-expressions" description:"draw the scalar expression trees of operators, such as conditions, as nodes linked with dotted edges"`
+	VariableFlows     bool     `long:"variable-flows" description:"draw edges from the operators that define variables to the operators that reference them"`
+	Highlight         string   `long:"highlight" description:"fill operators matching this expression, e.g. 'latency > 100ms || scanned_rows > 1e6'"`
+	Only              string   `long:"only" description:"render only operators matching this expression and their ancestors, e.g. 'display_name == \"Scan\"'"`
+	WrapWidth         int      `long:"wrap-width" description:"wrap scalar link descriptions, metadata values and the query text at this many display columns"`
+	MaxLines          int      `long:"max-lines" description:"truncate each wrapped item to this many lines and show the full text in the tooltip"`
+
+	MermaidLabelTemplateFile  string `long:"mermaid-label-template" description:"Go text/template file that lays out Mermaid node labels"`
+	GraphvizLabelTemplateFile string `long:"graphviz-label-template" description:"Go text/template file that lays out Graphviz node labels"`
+
+	// mermaidLabelTemplate and graphvizLabelTemplate are the contents of the template files, read by Normalize.
+	mermaidLabelTemplate  string
+	graphvizLabelTemplate string
+}
+
+// AdviseOptions are the flags of the advise subcommand.
+type AdviseOptions struct {
+	InputOptions
+
+	ConfigFile string `long:"config" description:"config file with default flags (default: the nearest .spannerplanviz.yaml in the working directory or its parents)"`
+
+	Filename     string   `long:"output"`
+	Format       string   `long:"format" description:"findings output format" default:"text" choice:"text" choice:"json"`                                                      // nolint:staticcheck
+	MinSeverity  string   `long:"min-severity" description:"report only findings with at least this severity" default:"info" choice:"info" choice:"warning" choice:"critical"` // nolint:staticcheck
+	Rules        []string `long:"rule" description:"run only this rule (repeatable)"`
+	DisableRules []string `long:"disable-rule" description:"skip this rule (repeatable)"`
 }
 
 // BuildOptions maps CLI flags to library build settings.
@@ -38,9 +101,54 @@ func (o *Options) BuildOptions() visualize.BuildOptions {
 		SerializeResult:   o.SerializeResult,
 		HideScanTarget:    o.HideScanTarget,
 		HideMetadata:      o.HideMetadata,
+		ShowMetadata:      o.ShowMetadata,
+		HideStats:         o.HideStats,
+		ShowStats:         o.ShowStats,
+		SelfTime:          o.SelfTime,
+		DurationUnit:      o.DurationUnit,
+		PercentOfQuery:    o.PercentOfQuery,
+		RootNode:          o.RootNode,
+		MaxDepth:          o.MaxDepth,
+		HideOperators:     o.HideOperators,
+		Compact:           o.Compact,
+		ScalarExpressions: o.ScalarExpressions,
+		VariableFlows:     o.VariableFlows,
+		Highlight:         o.Highlight,
+		Only:              o.Only,
+		WrapWidth:         o.WrapWidth,
+		MaxLines:          o.MaxLines,
+
+		MermaidLabelTemplate:  o.mermaidLabelTemplate,
+		GraphvizLabelTemplate: o.graphvizLabelTemplate,
 	}
 }
 
+// FetchRequest maps --sql and related flags to a fetch request.
+// It returns false if --sql is not set.
+func (o *InputOptions) FetchRequest() (fetch.Request, bool, error) {
+	if o.SQL == "" {
+		return fetch.Request{}, false, nil
+	}
+
+	params, err := fetch.ParseParams(o.Params)
+	if err != nil {
+		return fetch.Request{}, true, err
+	}
+
+	mode := fetch.Mode(o.Mode)
+	if mode == "" {
+		mode = fetch.ModePlan
+	}
+	return fetch.Request{
+		Project:  o.Project,
+		Instance: o.Instance,
+		Database: o.Database,
+		SQL:      o.SQL,
+		Mode:     mode,
+		Params:   params,
+	}, true, nil
+}
+
 func (o *Options) ApplyFullOption() {
 	if o.Full {
 		o.NonVariableScalar = true
@@ -52,16 +160,103 @@ func (o *Options) ApplyFullOption() {
 	}
 }
 
-// Normalize applies derived options and validates output settings for library callers.
+// Normalize applies derived options, reads label template files and validates output settings for library callers.
 func (o *Options) Normalize() error {
 	o.ApplyFullOption()
 	if o.TypeFlag == "" {
 		o.TypeFlag = "svg"
 	}
 	switch o.TypeFlag {
-	case "svg", "dot", "png", "mermaid":
-		return nil
+	case "svg", "dot", "png", "mermaid", "html":
 	default:
 		return fmt.Errorf("unsupported output type %q", o.TypeFlag)
 	}
+	if o.MaxDepth < 0 {
+		return fmt.Errorf("--max-depth must not be negative: %d", o.MaxDepth)
+	}
+	if o.WrapWidth < 0 {
+		return fmt.Errorf("--wrap-width must not be negative: %d", o.WrapWidth)
+	}
+	if o.MaxLines < 0 {
+		return fmt.Errorf("--max-lines must not be negative: %d", o.MaxLines)
+	}
+
+	for _, f := range []struct {
+		filename string
+		content  *string
+	}{
+		{o.MermaidLabelTemplateFile, &o.mermaidLabelTemplate},
+		{o.GraphvizLabelTemplateFile, &o.graphvizLabelTemplate},
+	} {
+		if f.filename == "" {
+			continue
+		}
+		b, err := os.ReadFile(f.filename)
+		if err != nil {
+			return fmt.Errorf("failed to read label template: %w", err)
+		}
+		*f.content = string(b)
+	}
+
+	return o.InputOptions.Validate()
+}
+
+// Validate checks the input format and the --sql options.
+func (o *InputOptions) Validate() error {
+	if _, err := planinput.ParseFormat(o.InputFormat); err != nil {
+		return err
+	}
+
+	if o.SQL != "" {
+		if o.Positional.Input != "" {
+			return fmt.Errorf("input file %q cannot be used with --sql", o.Positional.Input)
+		}
+		req, _, err := o.FetchRequest()
+		if err != nil {
+			return err
+		}
+		if err := req.Validate(); err != nil {
+			return fmt.Errorf("invalid --sql options: %w", err)
+		}
+	}
+	return nil
+}
+
+// Normalize validates the advise options.
+func (o *AdviseOptions) Normalize() error {
+	switch o.Format {
+	case "":
+		o.Format = "text"
+	case "text", "json":
+	default:
+		return fmt.Errorf("unsupported findings format %q", o.Format)
+	}
+
+	if o.MinSeverity == "" {
+		o.MinSeverity = "info"
+	}
+	if _, err := advisor.ParseSeverity(o.MinSeverity); err != nil {
+		return err
+	}
+	if _, err := o.SelectRules(advisor.DefaultRegistry()); err != nil {
+		return err
+	}
+	return o.InputOptions.Validate()
+}
+
+// SelectRules returns the rules of registry selected by --rule and --disable-rule.
+func (o *AdviseOptions) SelectRules(registry *advisor.Registry) ([]advisor.Rule, error) {
+	for _, name := range slices.Concat(o.Rules, o.DisableRules) {
+		if _, ok := registry.Lookup(name); !ok {
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+	}
+
+	rules := registry.Rules()
+	return slices.DeleteFunc(rules, func(rule advisor.Rule) bool {
+		if len(o.Rules) > 0 && !slices.Contains(o.Rules, rule.Name()) {
+			return true
+		}
+		return slices.Contains(o.DisableRules, rule.Name())
+	}), nil
 }
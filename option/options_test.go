@@ -1,6 +1,13 @@
 package option
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/apstndb/spannerplanviz/advisor"
+)
 
 func TestOptionsNormalize(t *testing.T) {
 	t.Run("defaults empty type to svg", func(t *testing.T) {
@@ -30,4 +37,74 @@ func TestOptionsNormalize(t *testing.T) {
 			t.Fatalf("Full option not applied: %+v", opts)
 		}
 	})
+
+	t.Run("validates sql options", func(t *testing.T) {
+		opts := Options{InputOptions: InputOptions{SQL: "SELECT 1", Project: "p", Instance: "i"}}
+		if err := opts.Normalize(); err == nil {
+			t.Fatal("Normalize() error = nil, want missing database error")
+		}
+
+		opts.Database = "d"
+		opts.Params = []string{"id:INT64=1"}
+		if err := opts.Normalize(); err != nil {
+			t.Fatalf("Normalize() error = %v", err)
+		}
+
+		opts.Positional.Input = "plan.json"
+		if err := opts.Normalize(); err == nil {
+			t.Fatal("Normalize() error = nil, want conflict with input file")
+		}
+	})
+
+	t.Run("reads label templates", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "label.tmpl")
+		if err := os.WriteFile(filename, []byte("{{.Title}}"), 0o644); err != nil {
+			t.Fatalf("write template: %v", err)
+		}
+		opts := Options{MermaidLabelTemplateFile: filename}
+		if err := opts.Normalize(); err != nil {
+			t.Fatalf("Normalize() error = %v", err)
+		}
+		if got := opts.BuildOptions().MermaidLabelTemplate; got != "{{.Title}}" {
+			t.Errorf("MermaidLabelTemplate = %q", got)
+		}
+
+		opts = Options{GraphvizLabelTemplateFile: filepath.Join(t.TempDir(), "missing.tmpl")}
+		if err := opts.Normalize(); err == nil {
+			t.Error("Normalize() error = nil, want missing file error")
+		}
+	})
+
+	t.Run("rejects invalid params", func(t *testing.T) {
+		opts := Options{InputOptions: InputOptions{SQL: "SELECT 1", Project: "p", Instance: "i", Database: "d", Params: []string{"id:INT64=x"}}}
+		if err := opts.Normalize(); err == nil {
+			t.Fatal("Normalize() error = nil, want parameter error")
+		}
+	})
+}
+
+func TestAdviseOptionsSelectRules(t *testing.T) {
+	registry := advisor.NewRegistry(advisor.BuiltinRules()...)
+	names := func(opts AdviseOptions) []string {
+		t.Helper()
+		rules, err := opts.SelectRules(registry)
+		if err != nil {
+			t.Fatalf("SelectRules() error = %v", err)
+		}
+		var names []string
+		for _, rule := range rules {
+			names = append(names, rule.Name())
+		}
+		return names
+	}
+
+	if got := names(AdviseOptions{Rules: []string{"spill-to-disk", "remote-calls"}}); !slices.Equal(got, []string{"remote-calls", "spill-to-disk"}) {
+		t.Errorf("SelectRules(--rule) = %v", got)
+	}
+	if got := names(AdviseOptions{DisableRules: []string{"full-table-scan"}}); slices.Contains(got, "full-table-scan") || len(got) != len(registry.Rules())-1 {
+		t.Errorf("SelectRules(--disable-rule) = %v", got)
+	}
+	if _, err := (&AdviseOptions{Rules: []string{"nope"}}).SelectRules(registry); err == nil {
+		t.Error("SelectRules() with unknown rule error = nil")
+	}
 }
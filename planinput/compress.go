@@ -0,0 +1,139 @@
+package planinput
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is a supported stream compression.
+type Compression string
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// CompressionFromFilename returns the compression implied by the extension of name.
+func CompressionFromFilename(name string) Compression {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gz":
+		return CompressionGzip
+	case ".zst":
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// TrimCompressionExt removes a .gz or .zst extension from name.
+func TrimCompressionExt(name string) string {
+	if CompressionFromFilename(name) == CompressionNone {
+		return name
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// NewReader returns a reader that transparently decompresses r if it starts with
+// gzip or zstd magic bytes. Decompression is streamed.
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return newDecompressor(br, CompressionGzip)
+	case bytes.HasPrefix(head, zstdMagic):
+		return newDecompressor(br, CompressionZstd)
+	default:
+		return io.NopCloser(br), nil
+	}
+}
+
+// Open opens the named file for reading. Files with a .gz or .zst extension are
+// decompressed accordingly; other files are decompressed if they start with known magic bytes.
+func Open(name string) (io.ReadCloser, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.ReadCloser
+	if c := CompressionFromFilename(name); c != CompressionNone {
+		r, err = newDecompressor(file, c)
+	} else {
+		r, err = NewReader(file)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &multiCloser{Reader: r, closers: []io.Closer{r, file}}, nil
+}
+
+func newDecompressor(r io.Reader, c Compression) (io.ReadCloser, error) {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", c)
+	}
+}
+
+// NewWriter wraps w to compress with c. The returned writer must be closed to flush
+// compressed data; closing it does not close w.
+func NewWriter(w io.Writer, c Compression) (io.WriteCloser, error) {
+	switch c {
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", c)
+	}
+}
+
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	var firstErr error
+	for _, c := range m.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,128 @@
+package planinput
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func compress(t *testing.T, data []byte, c Compression) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, c)
+	if err != nil {
+		t.Fatalf("NewWriter(%q) error = %v", c, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewReader(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`{"queryPlan": {"planNodes": []}}`)
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(string(c), func(t *testing.T) {
+			t.Parallel()
+
+			r, err := NewReader(bytes.NewReader(compress(t, data, c)))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			defer r.Close()
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("NewReader() = %q, want %q", got, data)
+			}
+		})
+	}
+
+	t.Run("short input", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := NewReader(bytes.NewReader([]byte("{}")))
+		if err != nil {
+			t.Fatalf("NewReader() error = %v", err)
+		}
+		if got, _ := io.ReadAll(r); string(got) != "{}" {
+			t.Errorf("NewReader() = %q, want {}", got)
+		}
+	})
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	data := []byte("planNodes: []\n")
+
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr bool
+	}{
+		{name: "plan.yaml", content: data},
+		{name: "plan.yaml.gz", content: compress(t, data, CompressionGzip)},
+		{name: "plan.yaml.zst", content: compress(t, data, CompressionZstd)},
+		{name: "sniffed.yaml", content: compress(t, data, CompressionGzip)},
+		{name: "mismatch.gz", content: data, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, tt.content, 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			r, err := Open(path)
+			if tt.wantErr {
+				if err == nil {
+					r.Close()
+					t.Fatal("Open() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer r.Close()
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("Open() = %q, want %q", got, data)
+			}
+		})
+	}
+}
+
+func TestTrimCompressionExt(t *testing.T) {
+	t.Parallel()
+
+	for in, want := range map[string]string{
+		"plan.svg.gz":  "plan.svg",
+		"plan.svg.ZST": "plan.svg",
+		"plan.svg":     "plan.svg",
+	} {
+		if got := TrimCompressionExt(in); got != want {
+			t.Errorf("TrimCompressionExt(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package planinput
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Format is an input encoding.
+type Format string
+
+const (
+	FormatAuto      Format = "auto"
+	FormatJSON      Format = "json"
+	FormatYAML      Format = "yaml"
+	FormatProtobuf  Format = "protobuf"
+	FormatPrototext Format = "prototext"
+)
+
+// autoFormats is the order in which FormatAuto tries formats for text input.
+var autoFormats = []Format{FormatJSON, FormatYAML, FormatPrototext, FormatProtobuf}
+
+// ParseFormat validates s as a Format. An empty string means FormatAuto.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "":
+		return FormatAuto, nil
+	case FormatAuto, FormatJSON, FormatYAML, FormatProtobuf, FormatPrototext:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported input format %q", s)
+	}
+}
+
+// IsText reports whether f may hold a JSON or YAML document, which is where
+// formats other than a plan (for example query profile exports) can appear.
+func (f Format) IsText() bool {
+	return f == FormatAuto || f == FormatJSON || f == FormatYAML
+}
+
+// Documents splits b into documents that can be passed to Extract.
+// Binary protobuf and prototext input is always a single document.
+func Documents(b []byte, format Format) [][]byte {
+	switch {
+	case format == FormatProtobuf, format == FormatPrototext:
+		return [][]byte{b}
+	case format == FormatAuto && looksBinary(b):
+		return [][]byte{b}
+	default:
+		return SplitDocuments(b)
+	}
+}
+
+// Extract parses a ResultSet, ResultSetStats or QueryPlan in the given format.
+// JSON and YAML are handled by spannerplan.ExtractQueryPlan.
+// FormatAuto tries each format in turn and reports every failure if none succeeds.
+func Extract(b []byte, format Format) (*sppb.ResultSetStats, *sppb.StructType, error) {
+	if format != FormatAuto {
+		return extract(b, format)
+	}
+
+	formats := autoFormats
+	if looksBinary(b) {
+		formats = []Format{FormatProtobuf}
+	}
+
+	var errs []error
+	for _, f := range formats {
+		stats, rowType, err := extract(b, f)
+		if err == nil {
+			return stats, rowType, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", f, err))
+	}
+
+	tried := make([]string, 0, len(formats))
+	for _, f := range formats {
+		tried = append(tried, string(f))
+	}
+	return nil, nil, fmt.Errorf("unable to detect input format (tried %s): %w", strings.Join(tried, ", "), errors.Join(errs...))
+}
+
+func extract(b []byte, format Format) (*sppb.ResultSetStats, *sppb.StructType, error) {
+	switch format {
+	case FormatJSON:
+		if !json.Valid(b) {
+			return nil, nil, errors.New("invalid JSON")
+		}
+		return spannerplan.ExtractQueryPlan(b)
+	case FormatYAML:
+		return spannerplan.ExtractQueryPlan(b)
+	case FormatProtobuf:
+		return extractProto(func(m proto.Message) error {
+			if err := proto.Unmarshal(b, m); err != nil {
+				return err
+			}
+			// Binary protobuf has no type information, so a message of the wrong type usually
+			// decodes without error but leaves fields it does not know as unknown fields.
+			if hasUnknownFields(m.ProtoReflect()) {
+				return errors.New("contains unknown fields")
+			}
+			return nil
+		})
+	case FormatPrototext:
+		return extractProto(func(m proto.Message) error {
+			return prototext.Unmarshal(b, m)
+		})
+	default:
+		return nil, nil, fmt.Errorf("unsupported input format %q", format)
+	}
+}
+
+// extractProto tries unmarshal with ResultSet, ResultSetStats and QueryPlan in that order,
+// and accepts the first one that yields a non-empty plan.
+func extractProto(unmarshal func(proto.Message) error) (*sppb.ResultSetStats, *sppb.StructType, error) {
+	var errs []error
+
+	var rs sppb.ResultSet
+	if err := unmarshal(&rs); err != nil {
+		errs = append(errs, fmt.Errorf("ResultSet: %w", err))
+	} else if len(rs.GetStats().GetQueryPlan().GetPlanNodes()) > 0 {
+		return rs.GetStats(), rs.GetMetadata().GetRowType(), nil
+	} else {
+		errs = append(errs, errors.New("ResultSet: no query plan"))
+	}
+
+	var rss sppb.ResultSetStats
+	if err := unmarshal(&rss); err != nil {
+		errs = append(errs, fmt.Errorf("ResultSetStats: %w", err))
+	} else if len(rss.GetQueryPlan().GetPlanNodes()) > 0 {
+		return &rss, nil, nil
+	} else {
+		errs = append(errs, errors.New("ResultSetStats: no query plan"))
+	}
+
+	var qp sppb.QueryPlan
+	if err := unmarshal(&qp); err != nil {
+		errs = append(errs, fmt.Errorf("QueryPlan: %w", err))
+	} else if len(qp.GetPlanNodes()) > 0 {
+		return &sppb.ResultSetStats{QueryPlan: &qp}, nil, nil
+	} else {
+		errs = append(errs, errors.New("QueryPlan: no plan nodes"))
+	}
+
+	return nil, nil, errors.Join(errs...)
+}
+
+func hasUnknownFields(m protoreflect.Message) bool {
+	if len(m.GetUnknown()) > 0 {
+		return true
+	}
+
+	found := false
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len() && !found; i++ {
+				found = hasUnknownFields(list.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				found = hasUnknownFields(mv.Message())
+				return !found
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			found = hasUnknownFields(v.Message())
+		}
+		return !found
+	})
+	return found
+}
+
+// looksBinary reports whether b cannot be a JSON, YAML or prototext document.
+func looksBinary(b []byte) bool {
+	if !utf8.Valid(b) {
+		return true
+	}
+	return bytes.ContainsFunc(b, func(r rune) bool {
+		return r < 0x20 && r != '\t' && r != '\n' && r != '\r'
+	})
+}
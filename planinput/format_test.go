@@ -0,0 +1,131 @@
+package planinput
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+func loadResultSet(t *testing.T) (*sppb.ResultSet, []byte) {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join("..", "visualize", "testdata", "dca_profile.json"))
+	if err != nil {
+		t.Fatalf("read dca_profile.json: %v", err)
+	}
+	stats, rowType, err := spannerplan.ExtractQueryPlan(b)
+	if err != nil {
+		t.Fatalf("ExtractQueryPlan() error = %v", err)
+	}
+	return &sppb.ResultSet{Metadata: &sppb.ResultSetMetadata{RowType: rowType}, Stats: stats}, b
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	rs, jsonBytes := loadResultSet(t)
+	mustMarshal := func(m proto.Message) []byte {
+		b, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatalf("proto.Marshal() error = %v", err)
+		}
+		return b
+	}
+	mustMarshalText := func(m proto.Message) []byte {
+		b, err := prototext.Marshal(m)
+		if err != nil {
+			t.Fatalf("prototext.Marshal() error = %v", err)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name        string
+		input       []byte
+		format      Format
+		wantRowType bool
+	}{
+		{name: "json", input: jsonBytes, format: FormatJSON, wantRowType: true},
+		{name: "yaml", input: []byte("planNodes:\n  - index: 0\n    kind: RELATIONAL\n    displayName: Scan\n"), format: FormatYAML},
+		{name: "protobuf ResultSet", input: mustMarshal(rs), format: FormatProtobuf, wantRowType: true},
+		{name: "protobuf ResultSetStats", input: mustMarshal(rs.GetStats()), format: FormatProtobuf},
+		{name: "protobuf QueryPlan", input: mustMarshal(rs.GetStats().GetQueryPlan()), format: FormatProtobuf},
+		{name: "prototext ResultSet", input: mustMarshalText(rs), format: FormatPrototext, wantRowType: true},
+		{name: "prototext QueryPlan", input: mustMarshalText(rs.GetStats().GetQueryPlan()), format: FormatPrototext},
+	}
+
+	for _, tt := range tests {
+		for _, format := range []Format{tt.format, FormatAuto} {
+			t.Run(tt.name+"/"+string(format), func(t *testing.T) {
+				t.Parallel()
+
+				stats, rowType, err := Extract(tt.input, format)
+				if err != nil {
+					t.Fatalf("Extract() error = %v", err)
+				}
+				if len(stats.GetQueryPlan().GetPlanNodes()) == 0 {
+					t.Fatal("Extract() returned no plan nodes")
+				}
+				if tt.wantRowType != (rowType != nil) {
+					t.Errorf("row type = %v, want present = %v", rowType, tt.wantRowType)
+				}
+			})
+		}
+	}
+}
+
+func TestExtract_errors(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := Extract([]byte("not a plan"), FormatAuto)
+	if err == nil {
+		t.Fatal("Extract() error = nil, want detection error")
+	}
+	for _, want := range []string{"tried json, yaml, prototext, protobuf", "json:", "yaml:", "prototext:", "protobuf:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Extract() error = %q, want it to contain %q", err, want)
+		}
+	}
+
+	if _, _, err := Extract([]byte("queryPlan: {}"), FormatJSON); err == nil {
+		t.Error("Extract(FormatJSON) accepted YAML")
+	}
+	if _, _, err := Extract([]byte{0x08, 0x01}, FormatProtobuf); err == nil {
+		t.Error("Extract(FormatProtobuf) accepted a message without plan nodes")
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	t.Parallel()
+
+	binary := []byte{0x0a, 0x02, '-', '-', '\n', '-', '-', '-', '\n', 0x01}
+	if got := Documents(binary, FormatAuto); len(got) != 1 {
+		t.Errorf("Documents(binary, auto) = %d documents, want 1", len(got))
+	}
+	if got := Documents([]byte("a: 1\n---\nb: 2\n"), FormatProtobuf); len(got) != 1 {
+		t.Errorf("Documents(protobuf) = %d documents, want 1", len(got))
+	}
+	if got := Documents([]byte("a: 1\n---\nb: 2\n"), FormatAuto); len(got) != 2 {
+		t.Errorf("Documents(yaml, auto) = %d documents, want 2", len(got))
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	if f, err := ParseFormat(""); err != nil || f != FormatAuto {
+		t.Errorf("ParseFormat(\"\") = %q, %v; want auto", f, err)
+	}
+	if f, err := ParseFormat("ProtoText"); err != nil || f != FormatPrototext {
+		t.Errorf("ParseFormat(\"ProtoText\") = %q, %v; want prototext", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(\"xml\") error = nil")
+	}
+}
@@ -0,0 +1,74 @@
+// Package planinput reads plan documents from raw CLI or library input.
+package planinput
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// SplitDocuments splits b into individual documents.
+//
+// Input starting with '{' or '[' is read as a sequence of JSON values, which covers
+// a single JSON document, JSON Lines and concatenated JSON. Anything else, or JSON that
+// fails to decode, is treated as a YAML stream separated by "---" document markers.
+// Empty documents are dropped.
+func SplitDocuments(b []byte) [][]byte {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		if docs, err := splitJSONValues(trimmed); err == nil {
+			return docs
+		}
+	}
+	return splitYAMLDocuments(b)
+}
+
+func splitJSONValues(b []byte) ([][]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	var docs [][]byte
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			return docs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		docs = append(docs, raw)
+	}
+}
+
+// splitYAMLDocuments splits on lines consisting of a "---" directives end marker or a "..." document end marker.
+// Markers only count at column 0, so indented block scalar content is never split.
+func splitYAMLDocuments(b []byte) [][]byte {
+	var docs [][]byte
+	var current []byte
+	flush := func() {
+		if len(bytes.TrimSpace(current)) > 0 {
+			docs = append(docs, current)
+		}
+		current = nil
+	}
+
+	for len(b) > 0 {
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line, b = b[:i+1], b[i+1:]
+		} else {
+			b = nil
+		}
+
+		trimmed := bytes.TrimRight(line, "\r\n")
+		switch {
+		case bytes.Equal(trimmed, []byte("---")), bytes.Equal(trimmed, []byte("...")):
+			flush()
+		case bytes.HasPrefix(trimmed, []byte("--- ")):
+			// Content may follow the marker on the same line, e.g. "--- {queryPlan: ...}".
+			flush()
+			current = append(current, line[len("--- "):]...)
+		default:
+			current = append(current, line...)
+		}
+	}
+	flush()
+	return docs
+}
@@ -0,0 +1,72 @@
+package planinput
+
+import (
+	"testing"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitDocuments(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "single JSON",
+			input: `{"queryPlan": {"planNodes": []}}`,
+			want:  []string{`{"queryPlan": {"planNodes": []}}`},
+		},
+		{
+			name:  "JSON Lines",
+			input: "{\"a\": 1}\n{\"b\": 2}\n\n{\"c\": 3}\n",
+			want:  []string{`{"a": 1}`, `{"b": 2}`, `{"c": 3}`},
+		},
+		{
+			name:  "single YAML",
+			input: "queryPlan:\n  planNodes: []\n",
+			want:  []string{"queryPlan:\n  planNodes: []\n"},
+		},
+		{
+			name: "YAML stream",
+			input: heredoc.Doc(`
+				---
+				a: 1
+				---
+				b: |
+				  ---
+				  not a marker
+				...
+				--- {c: 3}
+			`),
+			want: []string{"a: 1\n", "b: |\n  ---\n  not a marker\n", "{c: 3}\n"},
+		},
+		{
+			name:  "YAML flow mapping falls back to YAML",
+			input: "{a: 1}\n---\n{b: 2}",
+			want:  []string{"{a: 1}\n", "{b: 2}"},
+		},
+		{
+			name:  "empty",
+			input: "\n",
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			for _, doc := range SplitDocuments([]byte(tt.input)) {
+				got = append(got, string(doc))
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("SplitDocuments() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+// Package queryprofiles reads rows exported from the SPANNER_SYS.QUERY_PROFILES_TOP_MINUTE,
+// QUERY_PROFILES_TOP_10MINUTE and QUERY_PROFILES_TOP_HOUR tables.
+//
+// Two export shapes are accepted, as JSON or YAML:
+//   - a ResultSet with metadata.rowType and rows, as printed by
+//     gcloud spanner databases execute-sql --format=json
+//   - an array of objects keyed by column name
+//
+// The QUERY_PROFILE column may hold the profile as a JSON string or as an object.
+package queryprofiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan"
+	"github.com/apstndb/spannerplan/protoyaml"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Column names of the QUERY_PROFILES_TOP_* tables.
+const (
+	ColumnIntervalEnd     = "INTERVAL_END"
+	ColumnTextFingerprint = "TEXT_FINGERPRINT"
+	ColumnLatencySeconds  = "LATENCY_SECONDS"
+	ColumnQueryProfile    = "QUERY_PROFILE"
+)
+
+// ErrNotExport is returned by Parse when the input is not a QUERY_PROFILES_TOP_* export.
+var ErrNotExport = errors.New("input is not a SPANNER_SYS.QUERY_PROFILES_TOP_* export")
+
+// Profile is a single sampled query profile.
+type Profile struct {
+	IntervalEnd     string
+	TextFingerprint string
+	LatencySeconds  string
+
+	// Columns holds every exported column except QUERY_PROFILE, formatted as text.
+	Columns map[string]string
+
+	Stats   *sppb.ResultSetStats
+	RowType *sppb.StructType
+}
+
+// Description summarizes the identifying columns of p.
+func (p Profile) Description() string {
+	var parts []string
+	for _, kv := range [][2]string{
+		{ColumnTextFingerprint, p.TextFingerprint},
+		{ColumnIntervalEnd, p.IntervalEnd},
+		{ColumnLatencySeconds, p.LatencySeconds},
+	} {
+		if kv[1] != "" {
+			parts = append(parts, fmt.Sprintf("%s=%s", kv[0], kv[1]))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Parse extracts sampled profiles from an export. Rows with a NULL QUERY_PROFILE are skipped.
+// It returns ErrNotExport if b has no QUERY_PROFILE column.
+func Parse(b []byte) ([]Profile, error) {
+	j, err := protoyaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(j); len(trimmed) > 0 && trimmed[0] == '[' {
+		return parseObjects(trimmed)
+	}
+
+	var topLevel struct {
+		Metadata json.RawMessage `json:"metadata"`
+		Rows     json.RawMessage `json:"rows"`
+		Stats    json.RawMessage `json:"stats"`
+	}
+	if err := json.Unmarshal(j, &topLevel); err != nil || len(topLevel.Metadata) == 0 || len(topLevel.Stats) != 0 {
+		return nil, ErrNotExport
+	}
+
+	var rs sppb.ResultSet
+	if err := protoyaml.UnmarshalJSON(j, &rs); err != nil {
+		return nil, err
+	}
+	return parseResultSet(&rs)
+}
+
+func parseResultSet(rs *sppb.ResultSet) ([]Profile, error) {
+	fields := rs.GetMetadata().GetRowType().GetFields()
+	if !slices.ContainsFunc(fields, func(f *sppb.StructType_Field) bool {
+		return strings.EqualFold(f.GetName(), ColumnQueryProfile)
+	}) {
+		return nil, ErrNotExport
+	}
+
+	var profiles []Profile
+	for i, row := range rs.GetRows() {
+		columns := make(map[string]string, len(fields))
+		var rawProfile []byte
+		for j, field := range fields {
+			if j >= len(row.GetValues()) {
+				break
+			}
+			name := strings.ToUpper(field.GetName())
+			v := row.GetValues()[j]
+			if name == ColumnQueryProfile {
+				rawProfile = queryProfileBytes(v)
+				continue
+			}
+			columns[name] = formatValue(v)
+		}
+
+		profile, err := newProfile(columns, rawProfile)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		if profile != nil {
+			profiles = append(profiles, *profile)
+		}
+	}
+	return profiles, nil
+}
+
+func parseObjects(j []byte) ([]Profile, error) {
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.UseNumber()
+	var rows []map[string]json.RawMessage
+	if err := dec.Decode(&rows); err != nil {
+		return nil, ErrNotExport
+	}
+
+	var profiles []Profile
+	for i, row := range rows {
+		columns := make(map[string]string, len(row))
+		var rawProfile []byte
+		hasProfileColumn := false
+		for key, raw := range row {
+			name := strings.ToUpper(key)
+			if name == ColumnQueryProfile {
+				hasProfileColumn = true
+				rawProfile = rawQueryProfileBytes(raw)
+				continue
+			}
+			columns[name] = formatRawValue(raw)
+		}
+		if !hasProfileColumn {
+			return nil, ErrNotExport
+		}
+
+		profile, err := newProfile(columns, rawProfile)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		if profile != nil {
+			profiles = append(profiles, *profile)
+		}
+	}
+	return profiles, nil
+}
+
+func newProfile(columns map[string]string, rawProfile []byte) (*Profile, error) {
+	if len(rawProfile) == 0 {
+		return nil, nil
+	}
+
+	stats, rowType, err := spannerplan.ExtractQueryPlan(rawProfile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ColumnQueryProfile, err)
+	}
+	return &Profile{
+		IntervalEnd:     columns[ColumnIntervalEnd],
+		TextFingerprint: columns[ColumnTextFingerprint],
+		LatencySeconds:  columns[ColumnLatencySeconds],
+		Columns:         columns,
+		Stats:           stats,
+		RowType:         rowType,
+	}, nil
+}
+
+// queryProfileBytes returns the JSON document held by a QUERY_PROFILE value, which is a
+// string in Spanner's wire format but may already be an object in hand-written exports.
+func queryProfileBytes(v *structpb.Value) []byte {
+	switch v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return []byte(v.GetStringValue())
+	case *structpb.Value_StructValue:
+		b, _ := v.MarshalJSON()
+		return b
+	default:
+		return nil
+	}
+}
+
+func rawQueryProfileBytes(raw json.RawMessage) []byte {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []byte(s)
+	}
+	if string(raw) == "null" {
+		return nil
+	}
+	return raw
+}
+
+func formatValue(v *structpb.Value) string {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return k.StringValue
+	case *structpb.Value_NumberValue:
+		return strconv.FormatFloat(k.NumberValue, 'f', -1, 64)
+	case *structpb.Value_BoolValue:
+		return strconv.FormatBool(k.BoolValue)
+	case *structpb.Value_NullValue, nil:
+		return ""
+	default:
+		b, _ := v.MarshalJSON()
+		return string(b)
+	}
+}
+
+func formatRawValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
@@ -0,0 +1,93 @@
+package queryprofiles_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MakeNowJust/heredoc/v2"
+
+	"github.com/apstndb/spannerplanviz/queryprofiles"
+)
+
+func TestParse_resultSet(t *testing.T) {
+	t.Parallel()
+
+	input := heredoc.Doc(`
+		{
+		  "metadata": {"rowType": {"fields": [
+		    {"name": "INTERVAL_END", "type": {"code": "TIMESTAMP"}},
+		    {"name": "TEXT_FINGERPRINT", "type": {"code": "INT64"}},
+		    {"name": "LATENCY_SECONDS", "type": {"code": "FLOAT64"}},
+		    {"name": "QUERY_PROFILE", "type": {"code": "JSON"}}
+		  ]}},
+		  "rows": [
+		    ["2024-01-01T00:00:00Z", "-1234567890123456789", 0.5, "{\"queryPlan\": {\"planNodes\": [{\"index\": 0, \"kind\": \"RELATIONAL\", \"displayName\": \"Scan\"}]}}"],
+		    ["2024-01-01T00:01:00Z", "42", 1.25, null],
+		    ["2024-01-01T00:02:00Z", "42", 2, "{\"queryPlan\": {\"planNodes\": [{\"index\": 0, \"kind\": \"RELATIONAL\", \"displayName\": \"Distributed Union\"}]}}"]
+		  ]
+		}
+	`)
+
+	profiles, err := queryprofiles.Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("len(profiles) = %d, want 2 (NULL profile skipped)", len(profiles))
+	}
+
+	first := profiles[0]
+	if first.TextFingerprint != "-1234567890123456789" || first.IntervalEnd != "2024-01-01T00:00:00Z" || first.LatencySeconds != "0.5" {
+		t.Errorf("profiles[0] = %+v", first)
+	}
+	if got := first.Stats.GetQueryPlan().GetPlanNodes()[0].GetDisplayName(); got != "Scan" {
+		t.Errorf("profiles[0] root = %q, want Scan", got)
+	}
+	if got, want := first.Description(), "TEXT_FINGERPRINT=-1234567890123456789, INTERVAL_END=2024-01-01T00:00:00Z, LATENCY_SECONDS=0.5"; got != want {
+		t.Errorf("Description() = %q, want %q", got, want)
+	}
+}
+
+func TestParse_objects(t *testing.T) {
+	t.Parallel()
+
+	input := heredoc.Doc(`
+		- interval_end: "2024-01-01T00:00:00Z"
+		  text_fingerprint: 9007199254740993
+		  latency_seconds: 0.1
+		  query_profile:
+		    queryPlan:
+		      planNodes:
+		        - index: 0
+		          kind: RELATIONAL
+		          displayName: Scan
+	`)
+
+	profiles, err := queryprofiles.Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(profiles) != 1 {
+		t.Fatalf("len(profiles) = %d, want 1", len(profiles))
+	}
+	if got := profiles[0].TextFingerprint; got != "9007199254740993" {
+		t.Errorf("TextFingerprint = %q, want exact INT64", got)
+	}
+	if got := profiles[0].Stats.GetQueryPlan().GetPlanNodes()[0].GetDisplayName(); got != "Scan" {
+		t.Errorf("root = %q, want Scan", got)
+	}
+}
+
+func TestParse_notExport(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{
+		`{"queryPlan": {"planNodes": []}}`,
+		`{"metadata": {"rowType": {"fields": [{"name": "a"}]}}, "rows": []}`,
+		`[{"a": 1}]`,
+	} {
+		if _, err := queryprofiles.Parse([]byte(input)); !errors.Is(err, queryprofiles.ErrNotExport) {
+			t.Errorf("Parse(%s) error = %v, want ErrNotExport", input, err)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+// Package schema reads the tables and indexes of a Cloud Spanner database from its DDL,
+// so that plans can be annotated with key columns without access to the database.
+package schema
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/spanner/spansql"
+)
+
+// KeyPart is a column of a primary key or an index key.
+type KeyPart struct {
+	Column string
+	Desc   bool
+}
+
+// String formats k like DDL, e.g. "SingerId" or "ReleaseDate DESC".
+func (k KeyPart) String() string {
+	if k.Desc {
+		return k.Column + " DESC"
+	}
+	return k.Column
+}
+
+// Table is a table defined by CREATE TABLE.
+type Table struct {
+	Name       string
+	PrimaryKey []KeyPart
+	// Parent is the table this table is interleaved in, or "".
+	Parent string
+}
+
+// Index is a secondary index defined by CREATE INDEX.
+type Index struct {
+	Name  string
+	Table string
+	Key   []KeyPart
+	// Storing are the non-key columns stored in the index.
+	Storing      []string
+	Unique       bool
+	NullFiltered bool
+	// Parent is the table this index is interleaved in, or "".
+	Parent string
+}
+
+// Schema holds the tables and indexes of a database. Names are looked up
+// case-insensitively, like Spanner identifiers.
+type Schema struct {
+	tables  map[string]*Table
+	indexes map[string]*Index
+}
+
+// Load parses the DDL file filename.
+func Load(filename string) (*Schema, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(filename, string(b))
+}
+
+// Parse parses DDL statements separated by semicolons. Only CREATE TABLE and CREATE INDEX
+// statements are parsed; other statements, including ones spansql does not support such as
+// CREATE SEARCH INDEX, CREATE SEQUENCE or CREATE PROPERTY GRAPH, are ignored.
+// filename is only used in error messages.
+func Parse(filename, ddl string) (*Schema, error) {
+	s := &Schema{tables: make(map[string]*Table), indexes: make(map[string]*Index)}
+	for _, stmt := range splitStatements(ddl) {
+		if !isTableOrIndex(ddl[stmt.begin:stmt.end]) {
+			continue
+		}
+		// Pad the statement so that errors report its line in ddl.
+		padded := strings.Repeat("\n", strings.Count(ddl[:stmt.begin], "\n")) + ddl[stmt.begin:stmt.end]
+		parsed, err := spansql.ParseDDL(filename, padded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DDL: %w", err)
+		}
+		for _, stmt := range parsed.List {
+			s.add(stmt)
+		}
+	}
+	return s, nil
+}
+
+func (s *Schema) add(stmt spansql.DDLStmt) {
+	switch stmt := stmt.(type) {
+	case *spansql.CreateTable:
+		table := &Table{Name: string(stmt.Name), PrimaryKey: keyParts(stmt.PrimaryKey)}
+		if stmt.Interleave != nil {
+			table.Parent = string(stmt.Interleave.Parent)
+		}
+		s.tables[strings.ToLower(table.Name)] = table
+	case *spansql.CreateIndex:
+		index := &Index{
+			Name:         string(stmt.Name),
+			Table:        string(stmt.Table),
+			Key:          keyParts(stmt.Columns),
+			Unique:       stmt.Unique,
+			NullFiltered: stmt.NullFiltered,
+			Parent:       string(stmt.Interleave),
+		}
+		for _, column := range stmt.Storing {
+			index.Storing = append(index.Storing, string(column))
+		}
+		s.indexes[strings.ToLower(index.Name)] = index
+	}
+}
+
+// isTableOrIndex reports whether stmt is a CREATE TABLE or CREATE [UNIQUE] [NULL_FILTERED] INDEX statement.
+func isTableOrIndex(stmt string) bool {
+	words := strings.Fields(strings.ToUpper(strings.NewReplacer("(", " ", "`", " ").Replace(stmt)))
+	if len(words) == 0 || words[0] != "CREATE" {
+		return false
+	}
+	words = words[1:]
+	for len(words) > 0 && (words[0] == "UNIQUE" || words[0] == "NULL_FILTERED") {
+		words = words[1:]
+	}
+	return len(words) > 0 && (words[0] == "TABLE" || words[0] == "INDEX")
+}
+
+// statementRange is the text of a statement in the DDL, from its first token
+// to the terminating semicolon or the end of the DDL.
+type statementRange struct {
+	begin, end int
+}
+
+// splitStatements splits ddl at the semicolons outside of comments, string literals and
+// quoted identifiers. Leading comments are not part of a statement, and empty statements are dropped.
+func splitStatements(ddl string) []statementRange {
+	var stmts []statementRange
+	begin := -1
+	for i := 0; i < len(ddl); i++ {
+		switch c := ddl[i]; {
+		case c == ';':
+			if begin >= 0 {
+				stmts = append(stmts, statementRange{begin, i})
+			}
+			begin = -1
+			continue
+		case c == '#' || strings.HasPrefix(ddl[i:], "--"):
+			i = skipUntil(ddl, i, "\n") - 1
+			continue
+		case strings.HasPrefix(ddl[i:], "/*"):
+			i = skipUntil(ddl, i+2, "*/") - 1
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			continue
+		}
+
+		if begin < 0 {
+			begin = i
+		}
+		if c := ddl[i]; c == '\'' || c == '"' || c == '`' {
+			i = skipQuoted(ddl, i) - 1
+		}
+	}
+	if begin >= 0 {
+		stmts = append(stmts, statementRange{begin, len(ddl)})
+	}
+	return stmts
+}
+
+// skipUntil returns the offset after the first end at or after i, or len(s).
+func skipUntil(s string, i int, end string) int {
+	if j := strings.Index(s[i:], end); j >= 0 {
+		return i + j + len(end)
+	}
+	return len(s)
+}
+
+// skipQuoted returns the offset after the quoted string or identifier starting at i,
+// which may be triple-quoted and contain backslash escapes.
+func skipQuoted(s string, i int) int {
+	quote := s[i : i+1]
+	if strings.HasPrefix(s[i:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	for j := i + len(quote); j < len(s); j++ {
+		switch {
+		case s[j] == '\\':
+			j++
+		case strings.HasPrefix(s[j:], quote):
+			return j + len(quote)
+		}
+	}
+	return len(s)
+}
+
+func keyParts(parts []spansql.KeyPart) []KeyPart {
+	result := make([]KeyPart, 0, len(parts))
+	for _, part := range parts {
+		result = append(result, KeyPart{Column: string(part.Column), Desc: part.Desc})
+	}
+	return result
+}
+
+// Table returns the table with the given name, or nil. It is safe to call on a nil Schema.
+func (s *Schema) Table(name string) *Table {
+	if s == nil {
+		return nil
+	}
+	return s.tables[strings.ToLower(name)]
+}
+
+// Index returns the index with the given name, or nil. It is safe to call on a nil Schema.
+func (s *Schema) Index(name string) *Index {
+	if s == nil {
+		return nil
+	}
+	return s.indexes[strings.ToLower(name)]
+}
+
+// FormatKey formats key parts as a comma-separated list, e.g. "SingerId, AlbumId DESC".
+func FormatKey(key []KeyPart) string {
+	parts := make([]string, 0, len(key))
+	for _, part := range key {
+		parts = append(parts, part.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DescribeScanTarget returns label lines for the scan target of a plan operator:
+// the primary key and interleave parent of a table, and the key, STORING columns,
+// interleave parent and base table of an index. It returns nil for other scan types
+// and for names not in the schema.
+func (s *Schema) DescribeScanTarget(scanType, target string) []string {
+	switch scanType {
+	case "TableScan":
+		return s.Table(target).describe()
+	case "IndexScan":
+		return s.Index(target).describe(s.Table)
+	}
+	return nil
+}
+
+func (t *Table) describe() []string {
+	if t == nil {
+		return nil
+	}
+	lines := []string{"Primary key: " + FormatKey(t.PrimaryKey)}
+	if t.Parent != "" {
+		lines = append(lines, "Interleaved in: "+t.Parent)
+	}
+	return lines
+}
+
+func (i *Index) describe(table func(name string) *Table) []string {
+	if i == nil {
+		return nil
+	}
+	key := "Index key: " + FormatKey(i.Key)
+	var attributes []string
+	if i.Unique {
+		attributes = append(attributes, "UNIQUE")
+	}
+	if i.NullFiltered {
+		attributes = append(attributes, "NULL_FILTERED")
+	}
+	if len(attributes) > 0 {
+		key += " (" + strings.Join(attributes, ", ") + ")"
+	}
+
+	lines := []string{key}
+	if len(i.Storing) > 0 {
+		lines = append(lines, "Storing: "+strings.Join(i.Storing, ", "))
+	}
+	if i.Parent != "" {
+		lines = append(lines, "Interleaved in: "+i.Parent)
+	}
+	lines = append(lines, "Base table: "+i.Table)
+	if base := table(i.Table); base != nil {
+		lines = append(lines, "Primary key: "+FormatKey(base.PrimaryKey))
+	}
+	return lines
+}
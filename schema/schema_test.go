@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+const testDDL = `
+CREATE TABLE Singers (
+  SingerId INT64 NOT NULL,
+  FirstName STRING(1024),
+  LastName STRING(1024),
+) PRIMARY KEY (SingerId);
+
+CREATE TABLE Albums (
+  SingerId INT64 NOT NULL,
+  AlbumId INT64 NOT NULL,
+  AlbumTitle STRING(MAX),
+  ReleaseDate DATE,
+) PRIMARY KEY (SingerId, AlbumId),
+  INTERLEAVE IN PARENT Singers ON DELETE CASCADE;
+
+CREATE INDEX SingersByFirstLastName ON Singers(FirstName, LastName);
+
+CREATE UNIQUE NULL_FILTERED INDEX AlbumsByReleaseDate ON Albums(SingerId, ReleaseDate DESC) STORING (AlbumTitle), INTERLEAVE IN Singers;
+`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	s, err := Parse("schema.sql", testDDL)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if diff := cmp.Diff(&Table{
+		Name:       "Albums",
+		PrimaryKey: []KeyPart{{Column: "SingerId"}, {Column: "AlbumId"}},
+		Parent:     "Singers",
+	}, s.Table("albums")); diff != "" {
+		t.Errorf("Table(albums) mismatch (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff(&Index{
+		Name:         "AlbumsByReleaseDate",
+		Table:        "Albums",
+		Key:          []KeyPart{{Column: "SingerId"}, {Column: "ReleaseDate", Desc: true}},
+		Storing:      []string{"AlbumTitle"},
+		Unique:       true,
+		NullFiltered: true,
+		Parent:       "Singers",
+	}, s.Index("AlbumsByReleaseDate")); diff != "" {
+		t.Errorf("Index(AlbumsByReleaseDate) mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := FormatKey(s.Index("AlbumsByReleaseDate").Key); got != "SingerId, ReleaseDate DESC" {
+		t.Errorf("FormatKey() = %q", got)
+	}
+	if s.Table("Songs") != nil || s.Index("Singers") != nil {
+		t.Error("lookup of an undefined name returned non-nil")
+	}
+	if (*Schema)(nil).Table("Singers") != nil {
+		t.Error("nil Schema returned a table")
+	}
+}
+
+func TestParse_otherStatements(t *testing.T) {
+	t.Parallel()
+
+	s, err := Parse("schema.sql", `
+-- Semicolons in comments; and string literals do not end a statement.
+CREATE SEQUENCE MySequence OPTIONS (sequence_kind = "bit_reversed_positive");
+/* CREATE TABLE Commented (Id INT64) PRIMARY KEY (Id); */
+CREATE TABLE Singers (
+  SingerId INT64 NOT NULL,
+  Name STRING(MAX) DEFAULT ("unknown; singer"),
+) PRIMARY KEY (SingerId);
+CREATE SEARCH INDEX SingersByName ON Singers(NameTokens);
+CREATE PROPERTY GRAPH MusicGraph NODE TABLES (Singers);
+CREATE NULL_FILTERED INDEX SingersByName2 ON Singers(Name)
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := s.Table("Singers"); got == nil || FormatKey(got.PrimaryKey) != "SingerId" {
+		t.Errorf("Table(Singers) = %+v, want primary key SingerId", got)
+	}
+	if got := s.Index("SingersByName2"); got == nil || !got.NullFiltered {
+		t.Errorf("Index(SingersByName2) = %+v, want NULL_FILTERED index", got)
+	}
+	if s.Table("Commented") != nil || s.Index("SingersByName") != nil {
+		t.Error("statement in a comment or search index was parsed")
+	}
+}
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+
+	_, err := Parse("schema.sql", "CREATE SEQUENCE MySequence;\n\nCREATE TABLE Singers (SingerId INT64) PRIMARY KEY SingerId")
+	if err == nil {
+		t.Fatal("Parse() error = nil, want syntax error")
+	}
+	if !strings.Contains(err.Error(), "schema.sql:3") {
+		t.Errorf("Parse() error = %v, want position schema.sql:3", err)
+	}
+}
+
+var _ visualize.Schema = (*Schema)(nil)
+
+func TestDescribeScanTarget(t *testing.T) {
+	t.Parallel()
+
+	s, err := Parse("schema.sql", testDDL)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		scanType, target string
+		want             []string
+	}{
+		{"TableScan", "Albums", []string{"Primary key: SingerId, AlbumId", "Interleaved in: Singers"}},
+		{"IndexScan", "SingersByFirstLastName", []string{"Index key: FirstName, LastName", "Base table: Singers", "Primary key: SingerId"}},
+		{"IndexScan", "AlbumsByReleaseDate", []string{
+			"Index key: SingerId, ReleaseDate DESC (UNIQUE, NULL_FILTERED)",
+			"Storing: AlbumTitle",
+			"Interleaved in: Singers",
+			"Base table: Albums",
+			"Primary key: SingerId, AlbumId",
+		}},
+		{"TableScan", "Songs", nil},
+		{"BatchScan", "$v2", nil},
+	} {
+		if diff := cmp.Diff(tt.want, s.DescribeScanTarget(tt.scanType, tt.target)); diff != "" {
+			t.Errorf("DescribeScanTarget(%q, %q) mismatch (-want +got):\n%s", tt.scanType, tt.target, diff)
+		}
+	}
+}
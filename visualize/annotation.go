@@ -0,0 +1,72 @@
+package visualize
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Annotation is a note attached to a node by an analysis such as the plan advisor.
+// Renderers show the text below the node content and outline the node with Color.
+type Annotation struct {
+	Text string
+	// Color is a color name understood by both Graphviz and CSS, e.g. "red" or "orange".
+	Color string
+}
+
+// AddAnnotation attaches a to the node.
+func (n *TreeNode) AddAnnotation(a Annotation) {
+	n.Annotations = append(n.Annotations, a)
+}
+
+// OutlineColor returns the color of the first annotation, or "" if the node is not annotated.
+// A compacted node also takes the color of the operators merged into it.
+func (n *TreeNode) OutlineColor() string {
+	for _, a := range n.Annotations {
+		if a.Color != "" {
+			return a.Color
+		}
+	}
+	for _, merged := range n.Merged {
+		if color := merged.OutlineColor(); color != "" {
+			return color
+		}
+	}
+	return ""
+}
+
+// HighlightColor is the fill color of highlighted nodes, understood by both Graphviz and CSS.
+const HighlightColor = "lightyellow"
+
+// IsHighlighted reports whether the node or an operator merged into it is highlighted.
+func (n *TreeNode) IsHighlighted() bool {
+	if n.Highlighted {
+		return true
+	}
+	for _, merged := range n.Merged {
+		if merged.IsHighlighted() {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *TreeNode) annotationsGraphvizHTML() string {
+	var lines []string
+	for _, a := range n.Annotations {
+		text := escapeGraphvizHTMLLabelContent(a.Text)
+		if a.Color != "" {
+			text = fmt.Sprintf(`<font color="%s">%s</font>`, html.EscapeString(a.Color), text)
+		}
+		lines = append(lines, text)
+	}
+	return strings.Join(lines, `<br align="CENTER"/>`)
+}
+
+func (n *TreeNode) annotationsMermaidLabelParts() []string {
+	var parts []string
+	for _, a := range n.Annotations {
+		parts = append(parts, markupIfNotEmpty("b", escapeMermaidLabelContent(a.Text)))
+	}
+	return parts
+}
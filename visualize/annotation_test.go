@@ -0,0 +1,59 @@
+package visualize
+
+import (
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestTreeNodeAnnotations(t *testing.T) {
+	t.Parallel()
+
+	metadata, _ := structpb.NewStruct(map[string]any{"k": "v"})
+	tests := []struct {
+		name        string
+		planNode    *sppb.PlanNode
+		param       BuildOptions
+		wantHTML    string
+		wantMermaid string
+	}{
+		{
+			name:        "title only",
+			planNode:    &sppb.PlanNode{Index: 1, DisplayName: "Sort"},
+			wantHTML:    `<b>Sort</b><br align="CENTER"/><font color="red">spills &lt;a lot&gt;</font><br align="CENTER"/>note`,
+			wantMermaid: "<b>Sort</b>\n<b>spills&nbsp;&lt;a&nbsp;lot&gt;</b>\n<b>note</b>",
+		},
+		{
+			name:        "after metadata",
+			planNode:    &sppb.PlanNode{Index: 1, DisplayName: "Sort", Metadata: metadata},
+			param:       BuildOptions{Metadata: true},
+			wantHTML:    `<b>Sort</b><br align="CENTER"/>k=v<br align="left" /><font color="red">spills &lt;a lot&gt;</font><br align="CENTER"/>note`,
+			wantMermaid: "<b>Sort</b>\nk: v\n<b>spills&nbsp;&lt;a&nbsp;lot&gt;</b>\n<b>note</b>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			node := &TreeNode{planNode: tt.planNode}
+			node.AddAnnotation(Annotation{Text: "spills <a lot>", Color: "red"})
+			node.AddAnnotation(Annotation{Text: "note"})
+
+			if got := node.HTML(tt.param, nil); got != tt.wantHTML {
+				t.Errorf("HTML() = %q, want %q", got, tt.wantHTML)
+			}
+			if got := node.MermaidLabel(tt.param, nil); got != tt.wantMermaid {
+				t.Errorf("MermaidLabel() = %q, want %q", got, tt.wantMermaid)
+			}
+			if got := node.OutlineColor(); got != "red" {
+				t.Errorf("OutlineColor() = %q, want red", got)
+			}
+		})
+	}
+
+	if got := (&TreeNode{planNode: &sppb.PlanNode{}}).OutlineColor(); got != "" {
+		t.Errorf("OutlineColor() without annotations = %q, want empty", got)
+	}
+}
@@ -18,7 +18,64 @@ type BuildOptions struct {
 	ExecutionSummary  bool
 	SerializeResult   bool
 	HideScanTarget    bool
-	HideMetadata      []string
+	// HideMetadata and ShowMetadata filter metadata keys, and HideStats and ShowStats
+	// filter execution stat keys, including unknown stats, self time lines such as
+	// "latency (self)" and execution summary lines, whose keys are prefixed with
+	// "execution_summary.". A pattern is a path.Match glob such as "scan_*" or a regular
+	// expression between slashes such as "/^(rows|latency)$/". A key is shown if it matches
+	// the Show patterns, when there are any, and none of the Hide patterns.
+	HideMetadata []string
+	ShowMetadata []string
+	HideStats    []string
+	ShowStats    []string
+	// SelfTime adds exclusive latency and cpu_time lines next to the cumulative execution stats.
+	SelfTime bool
+	// DurationUnit displays all time stats in one unit ("usecs", "msecs", "secs" or "mins")
+	// instead of the unit reported for each stat.
+	DurationUnit string
+	// PercentOfQuery appends each operator's share of the query elapsed_time and cpu_time
+	// to its latency and cpu_time stats, e.g. "78.03 msecs (7.2% of elapsed)".
+	PercentOfQuery bool
+	// RootNode is the index of the operator to render the subtree of. 0 renders the whole plan.
+	RootNode int32
+	// MaxDepth is the number of operator levels to render, counting the root.
+	// Deeper operators are replaced with a "… K more operators" placeholder per cut-off child.
+	// The limit applies to the tree left by Only and HideOperators.
+	// 0 means no limit.
+	MaxDepth int
+	// HideOperators are path.Match patterns, such as "Serialize Result" or "Compute*",
+	// of operators to remove from the tree. A pattern is matched against both the display name
+	// and the title of an operator. The children of a hidden operator are linked to its
+	// nearest visible ancestor, and its self time is counted towards that ancestor.
+	HideOperators []string
+	// Compact merges chains of operators that have a single local child into one box.
+	Compact bool
+	// ScalarExpressions attaches the scalar expression trees of each operator, such as the
+	// Function, Reference and Constant nodes of a condition, to the operator with dotted edges.
+	ScalarExpressions bool
+	// VariableFlows adds Plan.VariableFlows, edges from the operators that define variables
+	// to the operators that reference them, e.g. from a Create Batch to its Batch Scan.
+	VariableFlows bool
+	// Schema adds the key columns, STORING columns and interleave parent of scan targets
+	// to scan operators. It is usually a *schema.Schema loaded from a DDL file.
+	Schema Schema
+	// MermaidLabelTemplate and GraphvizLabelTemplate are text/template sources that lay out
+	// the content of each operator in Mermaid and Graphviz labels. They are executed with
+	// the NodeLabel of the operator. Empty strings select DefaultMermaidLabelTemplate and
+	// DefaultGraphvizLabelTemplate.
+	MermaidLabelTemplate  string
+	GraphvizLabelTemplate string
+	// WrapWidth wraps scalar link descriptions, metadata values and the query text at this many
+	// display columns, counting East Asian wide characters twice. 0 disables wrapping.
+	WrapWidth int
+	// MaxLines truncates each of those items to this many lines with an ellipsis.
+	// The full text of truncated items is shown in the tooltip. 0 means no limit.
+	MaxLines int
+	// Highlight is an Expr source. Operators matching it are drawn with HighlightColor.
+	Highlight string
+	// Only is an Expr source. Operators that neither match it nor have a matching descendant
+	// are removed, so that the path from the root to every match stays visible.
+	Only string
 }
 
 // ApplyFull enables all detail flags used by the CLI --full preset.
@@ -56,6 +113,9 @@ type Plan struct {
 	RowType    *sppb.StructType
 	QueryStats *sppb.ResultSetStats
 	Build      BuildOptions
+	// VariableFlows are built with BuildOptions.VariableFlows. Renderers draw them apart
+	// from the child links, in VariableFlowColor.
+	VariableFlows []VariableFlow
 }
 
 // BuildPlan constructs a diagram model from query plan stats.
@@ -76,16 +136,60 @@ func BuildPlan(rowType *sppb.StructType, queryStats *sppb.ResultSetStats, opts B
 		return nil, fmt.Errorf("failed to process plan rows: %w", err)
 	}
 
-	rootNode, err := buildTree(qp, qp.GetNodeByIndex(0), rowType, opts, rowsByID)
+	if err := validateOperatorPatterns(opts.HideOperators); err != nil {
+		return nil, err
+	}
+	if err := validateLabelTemplates(opts); err != nil {
+		return nil, err
+	}
+	if err := opts.validateKeyFilters(); err != nil {
+		return nil, err
+	}
+	highlight, err := compileOptionalExpr("highlight", opts.Highlight, queryStats.GetQueryPlan().GetPlanNodes())
+	if err != nil {
+		return nil, err
+	}
+	only, err := compileOptionalExpr("only", opts.Only, queryStats.GetQueryPlan().GetPlanNodes())
+	if err != nil {
+		return nil, err
+	}
+
+	rootPlanNode, err := subtreeRoot(qp, opts.RootNode)
+	if err != nil {
+		return nil, err
+	}
+
+	rootNode, err := buildTree(qp, rootPlanNode, rowType, opts, rowsByID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build tree: %w", err)
 	}
+	setQueryTotals(rootNode, newQueryTotals(queryStats, qp.GetNodeByIndex(0)))
+
+	if only != nil {
+		keepMatching(rootNode, only)
+	}
+	rootNode = hideOperators(rootNode, opts.HideOperators)
+	cutAtDepth(rootNode, opts.MaxDepth)
+	if opts.Compact {
+		compactChains(rootNode)
+	}
+	linkParents(rootNode, nil)
 
-	return &Plan{
+	plan := &Plan{
 		Root:       rootNode,
 		QueryPlan:  qp,
 		RowType:    rowType,
 		QueryStats: queryStats,
 		Build:      opts,
-	}, nil
+	}
+	if opts.VariableFlows {
+		plan.VariableFlows = buildVariableFlows(qp, rootNode)
+	}
+	if highlight != nil {
+		plan.Walk(func(node *TreeNode) bool {
+			node.Highlighted = node.Highlighted || highlight.Match(node)
+			return true
+		})
+	}
+	return plan, nil
 }
@@ -38,6 +38,9 @@ func buildTree(qp *spannerplan.QueryPlan, planNode *sppb.PlanNode, rowType *sppb
 		edges = append(edges, edge)
 	}
 	node.Children = edges
+	if param.ScalarExpressions {
+		node.Expressions = buildExpressions(qp, planNode)
+	}
 	return node, nil
 }
 
@@ -57,6 +60,8 @@ type Link struct {
 	ChildType string
 	Style     EdgeStyle
 	ChildNode *TreeNode
+	// Highlighted links, such as the critical path, are drawn emphasized.
+	Highlighted bool
 }
 
 // isRemoteCall determines if a link between nodes represents a remote call in the Spanner query plan.
@@ -89,20 +94,33 @@ type TreeNode struct {
 
 	// Essential fields for graph structure
 	Children []*Link
-}
-
-// nodeContent holds the raw, unformatted content extracted from a plan node,
-// before any Mermaid-specific escaping or final text formatting.
-type nodeContent struct {
-	Title               string
-	ShortRepresentation string
-	ScanInfo            string
-	SerializeResult     []string
-	NonVarScalarLinks   []string
-	Metadata            map[string]string
-	VarScalarLinks      []string
-	Stats               map[string]string
-	ExecutionSummary    string
+	// Expressions are the scalar expression trees of the operator, built with
+	// BuildOptions.ScalarExpressions. Their nodes are SCALAR plan nodes, whose operands are
+	// in turn in Expressions, and they are not visited by Plan.Walk.
+	Expressions []*Link
+
+	// Annotations are rendered below the node content.
+	Annotations []Annotation
+
+	// Highlighted nodes, such as those matching BuildOptions.Highlight, are drawn with a fill color.
+	Highlighted bool
+
+	// Merged are the operators below this one that BuildOptions.Compact merged into its box,
+	// from top to bottom. Children are then the children of the last merged operator.
+	Merged []*TreeNode
+	// planChildren are the node's own children when Children were taken over from Merged.
+	planChildren []*Link
+
+	// queryTotals is shared by all nodes of a plan; it is nil for nodes not built by BuildPlan.
+	queryTotals *queryTotals
+	// omitted is the number of operators a placeholder node stands for, and omittedStats
+	// are their cumulative execution stats.
+	omitted      int
+	omittedStats *structpb.Struct
+
+	// parent and depth locate the node in the tree returned by BuildPlan.
+	parent *TreeNode
+	depth  int
 }
 
 // escapeMermaidLabelContent prepares a string for safe inclusion in a Mermaid HTML label.
@@ -135,119 +153,25 @@ func escapeGraphvizHTMLLabelContent(content string) string {
 	return replacer.Replace(content)
 }
 
-// getNodeContent extracts and formats the raw content of a treeNode into a structured nodeContent.
-// This function centralizes the logic for gathering all relevant displayable information
-// from a plan node, before any Mermaid-specific or plain-text-specific formatting.
-func (n *TreeNode) getNodeContent(param BuildOptions, rowType *sppb.StructType) nodeContent {
-	content := nodeContent{
-		Title:               n.GetTitle(),
-		ShortRepresentation: n.GetShortRepresentation(),
-		ScanInfo:            n.GetScanInfoOutput(param),
-		SerializeResult:     []string{},
-		NonVarScalarLinks:   []string{},
-		Metadata:            map[string]string{},
-		VarScalarLinks:      []string{},
-		Stats:               n.GetStats(param),
-		ExecutionSummary:    n.GetExecutionSummary(param),
-	}
-
-	if param.Metadata {
-		content.Metadata = n.GetMetadata(param)
-	}
-
-	appendMultiline := func(target *[]string, value string) {
-		for _, line := range strings.Split(strings.TrimSuffix(value, "\n"), "\n") {
-			if line != "" {
-				*target = append(*target, line)
-			}
-		}
-	}
-
-	if param.SerializeResult {
-		appendMultiline(&content.SerializeResult, n.GetSerializeResultOutput(rowType))
-	}
-	if param.NonVariableScalar {
-		appendMultiline(&content.NonVarScalarLinks, n.GetNonVarScalarLinksOutput())
-	}
-	if param.VariableScalar {
-		appendMultiline(&content.VarScalarLinks, n.GetVarScalarLinksOutput())
-	}
-
-	// Apply heuristic check for ScanInfo double-printing
-	// This logic is moved from MermaidLabel to here to centralize content decision.
-	isScanNode := n.planNode.GetDisplayName() == "Scan"
-	if isScanNode && content.ScanInfo != "" && content.ScanInfo == content.ShortRepresentation && content.ShortRepresentation != "" {
-		// If it's a scan node, and ScanInfo is identical to ShortRepresentation, and ShortRepresentation is not empty,
-		// then clear ScanInfo to avoid double-printing.
-		content.ScanInfo = ""
-	}
-
-	return content
-}
-
 // MermaidLabel generates the label string for this node, suitable for use in Mermaid diagrams.
+// The label of a compacted node lists the merged operators below its own content.
 func (n *TreeNode) MermaidLabel(param BuildOptions, rowType *sppb.StructType) string {
-	content := n.getNodeContent(param, rowType)
-	var labelParts []string
-
-	if content.Title != "" {
-		labelParts = append(labelParts, markupIfNotEmpty("b", escapeMermaidLabelContent(content.Title)))
-	}
-	if content.ShortRepresentation != "" {
-		labelParts = append(labelParts, escapeMermaidLabelContent(content.ShortRepresentation))
-	}
-	if content.ScanInfo != "" {
-		labelParts = append(labelParts, escapeMermaidLabelContent(content.ScanInfo))
-	}
-
-	for _, line := range content.SerializeResult {
-		labelParts = append(labelParts, escapeMermaidLabelContent(line))
-	}
-	for _, line := range content.NonVarScalarLinks {
-		labelParts = append(labelParts, escapeMermaidLabelContent(line))
-	}
-
-	if len(content.Metadata) > 0 {
-		var metaLines []string
-		var metaKeys []string
-		for k := range content.Metadata {
-			metaKeys = append(metaKeys, k)
-		}
-		sort.Strings(metaKeys)
-		for _, k := range metaKeys {
-			metaLines = append(metaLines, fmt.Sprintf("%s: %s", escapeMermaidLabelContent(k), escapeMermaidLabelContent(content.Metadata[k])))
-		}
-		labelParts = append(labelParts, metaLines...)
-	}
-
-	for _, line := range content.VarScalarLinks {
-		labelParts = append(labelParts, escapeMermaidLabelContent(line))
+	labelContent := n.operatorMermaidLabel(param, rowType)
+	for i, merged := range n.Merged {
+		labelContent += "\n" + escapeMermaidLabelContent(mergedSeparator(n.mergedLinkType(i))) + "\n" + merged.operatorMermaidLabel(param, rowType)
 	}
+	return labelContent
+}
 
-	if len(content.Stats) > 0 {
-		var statLines []string
-		var statKeys []string
-		for k := range content.Stats {
-			statKeys = append(statKeys, k)
-		}
-		sort.Strings(statKeys)
-		for _, k := range statKeys {
-			statLines = append(statLines, markupIfNotEmpty("i", fmt.Sprintf("%s: %s", escapeMermaidLabelContent(k), escapeMermaidLabelContent(content.Stats[k]))))
+func (n *TreeNode) operatorMermaidLabel(param BuildOptions, rowType *sppb.StructType) string {
+	var labelParts []string
+	for _, line := range strings.Split(executeLabelTemplate(labelBackendMermaid, param.MermaidLabelTemplate, n.Label(param, rowType)), "\n") {
+		if strings.TrimSpace(line) != "" {
+			labelParts = append(labelParts, line)
 		}
-		labelParts = append(labelParts, statLines...)
 	}
 
-	if content.ExecutionSummary != "" {
-		var summaryLines []string
-		for _, line := range strings.Split(strings.TrimSuffix(content.ExecutionSummary, "\n"), "\n") {
-			if line != "" {
-				summaryLines = append(summaryLines, escapeMermaidLabelContent(line))
-			}
-		}
-		if len(summaryLines) > 0 {
-			labelParts = append(labelParts, markupIfNotEmpty("i", strings.Join(summaryLines, "\n")))
-		}
-	}
+	labelParts = append(labelParts, n.annotationsMermaidLabelParts()...)
 
 	labelContent := strings.Join(labelParts, "\n")
 	if labelContent == "" {
@@ -263,9 +187,23 @@ func (n *TreeNode) GetName() string {
 	if n.planNode == nil {
 		return "node_unknown" // Fallback for safety, though planNode should always be set
 	}
+	if n.omitted > 0 {
+		return fmt.Sprintf("node%d_omitted", n.planNode.GetIndex())
+	}
 	return fmt.Sprintf("node%d", n.planNode.GetIndex())
 }
 
+// PlanNode returns the underlying plan node.
+func (n *TreeNode) PlanNode() *sppb.PlanNode {
+	return n.planNode
+}
+
+// PlanRow returns the rendered plan row of the node, which holds its scalar child links.
+// It is nil unless the plan was built with scalar links or serialize result enabled.
+func (n *TreeNode) PlanRow() *plantree.RowWithPredicates {
+	return n.planRow
+}
+
 // GetTooltip generates the tooltip string (YAML of the planNode) on demand.
 func (n *TreeNode) GetTooltip() (string, error) {
 	tooltipBytes, err := yaml.Marshal(n.planNode)
@@ -275,6 +213,31 @@ func (n *TreeNode) GetTooltip() (string, error) {
 	return string(tooltipBytes), nil
 }
 
+// Tooltip returns the tooltip of the node as rendered with param: the full text of the items
+// that BuildOptions.MaxLines truncated in its label or in the labels of the operators merged
+// into it, followed by GetTooltip.
+func (n *TreeNode) Tooltip(param BuildOptions, rowType *sppb.StructType) (string, error) {
+	tooltip, err := n.GetTooltip()
+	if err != nil {
+		return "", err
+	}
+	truncated := n.TruncatedText(param, rowType)
+	if truncated == "" {
+		return tooltip, nil
+	}
+	return truncated + "\n\n" + tooltip, nil
+}
+
+// TruncatedText returns the full text of the items that BuildOptions.MaxLines truncated
+// in the label of the node or in the labels of the operators merged into it, one per line.
+func (n *TreeNode) TruncatedText(param BuildOptions, rowType *sppb.StructType) string {
+	var truncated []string
+	for _, node := range append([]*TreeNode{n}, n.Merged...) {
+		truncated = append(truncated, node.Label(param, rowType).Truncated...)
+	}
+	return strings.Join(truncated, "\n")
+}
+
 func (n *TreeNode) GetTitle() string {
 	return spannerplan.NodeTitle(n.planNode, spannerplan.HideMetadata())
 }
@@ -319,7 +282,7 @@ func (n *TreeNode) GetVarScalarLinksOutput() string {
 func (n *TreeNode) GetMetadata(param BuildOptions) map[string]string {
 	result := make(map[string]string)
 	for k, v := range n.planNode.GetMetadata().GetFields() {
-		if slices.Contains(param.HideMetadata, k) || slices.Contains(internalMetadataKeys, k) {
+		if !param.metadataVisible(k) || slices.Contains(internalMetadataKeys, k) {
 			continue
 		}
 		result[k] = fmt.Sprint(v.AsInterface())
@@ -332,11 +295,23 @@ func (n *TreeNode) GetStats(param BuildOptions) map[string]string {
 		return nil
 	}
 
-	es, err := extractExecutionStats(n.planNode)
+	statsNode := n.statsNode()
+	es, err := extractExecutionStats(statsNode)
 	if err != nil || es == nil {
 		return nil
 	}
-	return executionStatsToMap(n.planNode, es)
+	statsMap := executionStatsToMap(statsNode, es)
+	formatDurationStats(statsNode, statsMap, param.DurationUnit)
+	if param.SelfTime {
+		maps.Copy(statsMap, n.selfTimeStats(param.DurationUnit))
+	}
+	if param.PercentOfQuery {
+		n.appendPercentOfQuery(statsMap)
+	}
+	maps.DeleteFunc(statsMap, func(key, _ string) bool {
+		return !param.statVisible(key)
+	})
+	return statsMap
 }
 
 func (n *TreeNode) GetExecutionSummary(param BuildOptions) string {
@@ -344,98 +319,52 @@ func (n *TreeNode) GetExecutionSummary(param BuildOptions) string {
 		return ""
 	}
 
-	es, err := extractExecutionStats(n.planNode)
+	statsNode := n.statsNode()
+	es, err := extractExecutionStats(statsNode)
 	if err != nil || es == nil {
 		return ""
 	}
-	return formatExecutionSummary(n.planNode, es.ExecutionSummary)
+	return formatExecutionSummary(statsNode, es.ExecutionSummary, func(key string) bool {
+		return param.statVisible(executionSummaryKeyPrefix + key)
+	})
 }
 
 // Metadata formats node content for GraphViz HTML-like labels.
+// It is the label laid out by param.GraphvizLabelTemplate without the title.
 func (n *TreeNode) Metadata(param BuildOptions, rowType *sppb.StructType) string {
-	content := n.getNodeContent(param, rowType)
-	var labelLines []string
-
-	if content.ShortRepresentation != "" {
-		labelLines = append(labelLines, escapeGraphvizHTMLLabelContent(content.ShortRepresentation))
-	}
-	if content.ScanInfo != "" {
-		labelLines = append(labelLines, escapeGraphvizHTMLLabelContent(content.ScanInfo))
-	}
-
-	for _, line := range content.SerializeResult {
-		labelLines = append(labelLines, escapeGraphvizHTMLLabelContent(line))
-	}
-	for _, line := range content.NonVarScalarLinks {
-		labelLines = append(labelLines, escapeGraphvizHTMLLabelContent(line))
-	}
-
-	if len(content.Metadata) > 0 {
-		var metaKVLines []string
-		var metaKeys []string
-		for k := range content.Metadata {
-			metaKeys = append(metaKeys, k)
-		}
-		sort.Strings(metaKeys)
-		for _, k := range metaKeys {
-			metaKVLines = append(metaKVLines, fmt.Sprintf("%s=%s", escapeGraphvizHTMLLabelContent(k), escapeGraphvizHTMLLabelContent(content.Metadata[k])))
-		}
-		labelLines = append(labelLines, metaKVLines...)
-	}
-
-	for _, line := range content.VarScalarLinks {
-		labelLines = append(labelLines, escapeGraphvizHTMLLabelContent(line))
-	}
-
-	// All lines in labelLines are now raw strings (or escaped key=value), to be processed by toLeftAlignedText.
-	labelHTMLPart := toLeftAlignedText(strings.Join(labelLines, "\n"))
+	label := n.Label(param, rowType)
+	label.Title = ""
+	return executeLabelTemplate(labelBackendGraphviz, param.GraphvizLabelTemplate, label)
+}
 
-	// Reconstruct content similar to old n.Stats (detailed stats + summary)
-	var statsAndSummaryPlainLines []string
-	if len(content.Stats) > 0 {
-		var statKVLines []string
-		var statKeys []string
-		for k := range content.Stats {
-			statKeys = append(statKeys, k)
-		}
-		sort.Strings(statKeys)
-		for _, k := range statKeys {
-			statKVLines = append(statKVLines, fmt.Sprintf("%s: %s", escapeGraphvizHTMLLabelContent(k), escapeGraphvizHTMLLabelContent(content.Stats[k])))
-		}
-		statsAndSummaryPlainLines = append(statsAndSummaryPlainLines, statKVLines...)
+func (n *TreeNode) HTML(param BuildOptions, rowType *sppb.StructType) string {
+	result := n.operatorHTML(param, rowType)
+	for i, merged := range n.Merged {
+		result = appendGraphvizHTMLLine(result, escapeGraphvizHTMLLabelContent(mergedSeparator(n.mergedLinkType(i))))
+		result = appendGraphvizHTMLLine(result, merged.operatorHTML(param, rowType))
 	}
+	return result
+}
 
-	if content.ExecutionSummary != "" {
-		for _, line := range strings.Split(strings.TrimSuffix(content.ExecutionSummary, "\n"), "\n") {
-			if line != "" {
-				statsAndSummaryPlainLines = append(statsAndSummaryPlainLines, escapeGraphvizHTMLLabelContent(line))
-			}
-		}
+// appendGraphvizHTMLLine appends line to an HTML-like label on a new centered line.
+func appendGraphvizHTMLLine(label, line string) string {
+	// Metadata and stats already end with a line break when they are not empty.
+	if !strings.HasSuffix(strings.TrimSuffix(label, "</i>"), `<br align="left" />`) {
+		label += `<br align="CENTER"/>`
 	}
-	// All lines in statsAndSummaryPlainLines are raw strings, toLeftAlignedText will escape them.
-	statsHTMLPart := markupIfNotEmpty("i", toLeftAlignedText(strings.Join(statsAndSummaryPlainLines, "\n")))
-	return labelHTMLPart + statsHTMLPart
+	return label + line
 }
 
-func (n *TreeNode) HTML(param BuildOptions, rowType *sppb.StructType) string {
-	titleHTML := ""
-	if t := n.GetTitle(); t != "" {
-		// n.GetTitle calls spannerplan.NodeTitle which already HTML escapes its content.
-		titleHTML = markupIfNotEmpty("b", t)
+func (n *TreeNode) operatorHTML(param BuildOptions, rowType *sppb.StructType) string {
+	result := executeLabelTemplate(labelBackendGraphviz, param.GraphvizLabelTemplate, n.Label(param, rowType))
+	if result == "" {
+		result = html.EscapeString(n.GetName())
 	}
 
-	metadataHTML := n.Metadata(param, rowType)
-
-	if titleHTML == "" && metadataHTML == "" {
-		return html.EscapeString(n.GetName())
-	}
-	if titleHTML == "" {
-		return metadataHTML
+	if annotationsHTML := n.annotationsGraphvizHTML(); annotationsHTML != "" {
+		result = appendGraphvizHTMLLine(result, annotationsHTML)
 	}
-	if metadataHTML == "" {
-		return titleHTML
-	}
-	return fmt.Sprintf(`%s<br align="CENTER"/>%s`, titleHTML, metadataHTML)
+	return result
 }
 
 func buildNode(planNode *sppb.PlanNode, rowsByID map[int32]plantree.RowWithPredicates) (*TreeNode, error) {
@@ -503,6 +432,14 @@ func prefixIfNotEmpty(prefix, value string) string {
 	return prefix + value
 }
 
+// queryTextKey is the query stat holding the query text.
+const queryTextKey = "query_text"
+
+// QueryText returns the query text of queryStats.
+func QueryText(queryStats map[string]*structpb.Value) string {
+	return queryStats[queryTextKey].GetStringValue()
+}
+
 func formatQueryStats(stats map[string]*structpb.Value) string {
 	var result []string
 	for k, v := range stats {
@@ -514,9 +451,14 @@ func formatQueryStats(stats map[string]*structpb.Value) string {
 }
 
 func FormatQueryNode(queryStats map[string]*structpb.Value, showQueryStats bool) string {
+	return FormatQueryNodeWithOptions(queryStats, showQueryStats, BuildOptions{})
+}
+
+// FormatQueryNodeWithOptions is FormatQueryNode with the query text wrapped and truncated
+// as BuildOptions.WrapWidth and BuildOptions.MaxLines select.
+func FormatQueryNodeWithOptions(queryStats map[string]*structpb.Value, showQueryStats bool, param BuildOptions) string {
 	m := maps.Clone(queryStats)
-	const queryTextKey = "query_text"
-	text := m[queryTextKey].GetStringValue()
+	text := newLabelWrapper(param).block(m[queryTextKey].GetStringValue())
 	delete(m, queryTextKey)
 	var buf strings.Builder
 	buf.WriteString(markupIfNotEmpty("b", toLeftAlignedText(escapeGraphvizHTMLLabelContent(text)))) // Changed to toLeftAlignedText
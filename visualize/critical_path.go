@@ -0,0 +1,100 @@
+package visualize
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CriticalPath returns the chain of operators that accounts for most of the query latency.
+// Starting at the root, it repeatedly descends into the child with the largest latency.
+// Children without a latency stat, such as Create Batch, are ranked by the largest latency
+// in their subtree. The path ends at the first operator with no descendant reporting latency.
+// It returns nil if the plan has no latency stats.
+func (p *Plan) CriticalPath() []*TreeNode {
+	if p == nil || p.Root == nil {
+		return nil
+	}
+	if _, ok := subtreeLatency(p.Root); !ok {
+		return nil
+	}
+
+	path := []*TreeNode{p.Root}
+	for node := p.Root; ; {
+		next := criticalChild(node)
+		if next == nil {
+			return path
+		}
+		path = append(path, next.ChildNode)
+		node = next.ChildNode
+	}
+}
+
+// HighlightCriticalPath marks the links along CriticalPath as highlighted and returns the path.
+func (p *Plan) HighlightCriticalPath() []*TreeNode {
+	path := p.CriticalPath()
+	for i := 0; i+1 < len(path); i++ {
+		for _, link := range path[i].Children {
+			if link.ChildNode == path[i+1] {
+				link.Highlighted = true
+			}
+		}
+	}
+	return path
+}
+
+// FormatCriticalPath formats path as one operator per line with its cumulative and self latency.
+// Latency totals are formatted like the stats of the diagram, in durationUnit if it is not empty
+// and in the reported unit otherwise.
+func FormatCriticalPath(path []*TreeNode, durationUnit string) string {
+	var sb strings.Builder
+	for i, node := range path {
+		latency := "-"
+		if v, ok := node.latencyStat(); ok {
+			v.HasMean, v.HasStdDev = false, false
+			latency = v.Format(durationUnit)
+			if self, ok := node.selfTimeStats(durationUnit)["latency (self)"]; ok {
+				latency += ", self: " + self
+			}
+		}
+		fmt.Fprintf(&sb, "%s%s %s (latency: %s)\n", strings.Repeat("  ", i), node.GetName(), node.GetTitle(), latency)
+	}
+	return sb.String()
+}
+
+// latencyStat returns the parsed latency stat of node.
+func (n *TreeNode) latencyStat() (StatValue, bool) {
+	v, err := ParseStatValue("latency", n.statsNode().GetExecutionStats().GetFields()["latency"])
+	return v, err == nil && v.Kind == StatKindDuration
+}
+
+func criticalChild(node *TreeNode) *Link {
+	var best *Link
+	var bestLatency time.Duration
+	for _, link := range node.Children {
+		latency, ok := subtreeLatency(link.ChildNode)
+		if !ok {
+			continue
+		}
+		if best == nil || latency > bestLatency {
+			best, bestLatency = link, latency
+		}
+	}
+	return best
+}
+
+// subtreeLatency returns the latency of node, or the largest latency below it if node has none.
+func subtreeLatency(node *TreeNode) (time.Duration, bool) {
+	if d, ok := statDuration(node.statsNode(), "latency"); ok {
+		return d, true
+	}
+
+	var maxLatency time.Duration
+	found := false
+	for _, link := range node.Children {
+		if d, ok := subtreeLatency(link.ChildNode); ok && (!found || d > maxLatency) {
+			maxLatency, found = d, true
+		}
+	}
+	return maxLatency, found
+}
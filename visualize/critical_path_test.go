@@ -0,0 +1,119 @@
+package visualize
+
+import (
+	"testing"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlanCriticalPath(t *testing.T) {
+	t.Parallel()
+
+	plan := mustBuildTestPlan(t, "dca_profile.json", BuildOptions{})
+	path := plan.HighlightCriticalPath()
+
+	var names []string
+	for _, node := range path {
+		names = append(names, node.GetName())
+	}
+	want := []string{"node0", "node18", "node19", "node27", "node28", "node29"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("CriticalPath() mismatch (-want +got):\n%s", diff)
+	}
+
+	var highlighted []string
+	var walk func(*TreeNode)
+	walk = func(node *TreeNode) {
+		for _, link := range node.Children {
+			if link.Highlighted {
+				highlighted = append(highlighted, node.GetName()+"->"+link.ChildNode.GetName())
+			}
+			walk(link.ChildNode)
+		}
+	}
+	walk(plan.Root)
+	wantHighlighted := []string{"node0->node18", "node18->node19", "node19->node27", "node27->node28", "node28->node29"}
+	if diff := cmp.Diff(wantHighlighted, highlighted); diff != "" {
+		t.Errorf("highlighted links mismatch (-want +got):\n%s", diff)
+	}
+
+	tests := []struct {
+		durationUnit string
+		want         string
+	}{
+		{"", "node0 Distributed Cross Apply (latency: 1.08 secs, self: 2.85 msecs)\n" +
+			"  node18 Serialize Result (latency: 998.11 msecs, self: 1.53 msecs)\n" +
+			"    node19 Cross Apply (latency: 996.58 msecs, self: 1.39 msecs)\n" +
+			"      node27 Local Distributed Union (latency: 995.19 msecs, self: 890 usecs)\n" +
+			"        node28 Filter Scan (latency: -)\n" +
+			"          node29 Table Scan (latency: 994.3 msecs, self: 994.3 msecs)\n"},
+		{"msecs", "node0 Distributed Cross Apply (latency: 1080 msecs, self: 2.85 msecs)\n" +
+			"  node18 Serialize Result (latency: 998.11 msecs, self: 1.53 msecs)\n" +
+			"    node19 Cross Apply (latency: 996.58 msecs, self: 1.39 msecs)\n" +
+			"      node27 Local Distributed Union (latency: 995.19 msecs, self: 0.89 msecs)\n" +
+			"        node28 Filter Scan (latency: -)\n" +
+			"          node29 Table Scan (latency: 994.3 msecs, self: 994.3 msecs)\n"},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.want, FormatCriticalPath(path, tt.durationUnit)); diff != "" {
+			t.Errorf("FormatCriticalPath(%q) mismatch (-want +got):\n%s", tt.durationUnit, diff)
+		}
+	}
+}
+
+func TestPlanCriticalPath_units(t *testing.T) {
+	t.Parallel()
+
+	// 0.5 secs must outrank 900 msecs only after unit conversion.
+	plan, err := BuildPlan(nil, &sppb.ResultSetStats{QueryPlan: &sppb.QueryPlan{PlanNodes: []*sppb.PlanNode{
+		{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Union All", ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 1}, {ChildIndex: 2}}, ExecutionStats: durationStats(t, "secs", "1", "")},
+		{Index: 1, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Scan", ExecutionStats: durationStats(t, "usecs", "900", "")},
+		{Index: 2, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Scan", ExecutionStats: durationStats(t, "secs", "0.5", "")},
+	}}}, BuildOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+
+	path := plan.CriticalPath()
+	if len(path) != 2 || path[1].GetName() != "node2" {
+		t.Errorf("CriticalPath() = %v, want node0 -> node2", path)
+	}
+}
+
+func TestPlanCriticalPath_noStats(t *testing.T) {
+	t.Parallel()
+
+	plan, err := BuildPlan(nil, &sppb.ResultSetStats{QueryPlan: &sppb.QueryPlan{PlanNodes: []*sppb.PlanNode{
+		{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Scan"},
+	}}}, BuildOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+	if path := plan.HighlightCriticalPath(); path != nil {
+		t.Errorf("CriticalPath() = %v, want nil", path)
+	}
+}
+
+func TestStatDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		total, unit string
+		want        time.Duration
+		wantOK      bool
+	}{
+		{"1.5", "secs", 1500 * time.Millisecond, true},
+		{"78.03", "msecs", 78030 * time.Microsecond, true},
+		{"12", "usecs", 12 * time.Microsecond, true},
+		{"3", "rows", 0, false},
+		{"x", "msecs", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := statDuration(&sppb.PlanNode{ExecutionStats: durationStats(t, tt.unit, tt.total, "")}, "latency")
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("statDuration(%s %s) = %v, %v; want %v, %v", tt.total, tt.unit, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,544 @@
+package visualize
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Expr is a compiled node expression, such as `latency > 100ms || scanned_rows > 1e6`
+// or `display_name == "Scan" && scan_target =~ "^Singers"`.
+//
+// Identifiers name a property of the node:
+//   - display_name, title, name ("node3") and index
+//   - an execution stat such as latency, rows or scanned_rows, as its total in the canonical
+//     unit of StatValue: seconds for durations and bytes for sizes
+//   - a metadata key such as scan_type, scan_target or call_type
+//
+// Names that are not identifiers, such as `Rows Spooled`, are written in backquotes.
+// A number may be directly followed by a duration unit, as in 100ms, 1.5s or 2msecs,
+// or a size unit, as in 64KB or 1MBytes, which converts it to seconds or bytes.
+// Strings are double- or single-quoted, without escape sequences.
+//
+// Operators, from the lowest precedence: ||, &&, !, and the comparisons ==, !=, <, <=, >, >=
+// and =~ (regular expression match, with a string literal on the right).
+// Values are compared as numbers if both sides are numeric and as strings otherwise;
+// <, <=, > and >= are false for non-numeric values. Any comparison with a property the node
+// does not have is false. A bare identifier is true if the property is present and not
+// false, zero or empty. BuildPlan rejects identifiers that no operator of the plan has.
+type Expr struct {
+	source string
+	root   exprNode
+}
+
+// CompileExpr parses a node expression.
+func CompileExpr(source string) (*Expr, error) {
+	p := &exprParser{source: source}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+// String returns the source of e.
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Match reports whether node satisfies e. Placeholder nodes never match.
+func (e *Expr) Match(node *TreeNode) bool {
+	if node.omitted > 0 {
+		return false
+	}
+	return e.root.eval(node).truthy()
+}
+
+// compileOptionalExpr compiles source, or returns nil if it is empty.
+// Identifiers that no operator of planNodes has are reported as errors, because they would
+// silently match nothing.
+func compileOptionalExpr(name, source string, planNodes []*sppb.PlanNode) (*Expr, error) {
+	if source == "" {
+		return nil, nil
+	}
+	expr, err := CompileExpr(source)
+	if err == nil {
+		err = expr.checkIdents(planNodes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s expression: %w", name, err)
+	}
+	return expr, nil
+}
+
+// builtinIdents are the identifiers that every node has.
+var builtinIdents = []string{"display_name", "title", "name", "index"}
+
+// checkIdents returns an error for the first identifier of e that is neither built in
+// nor an execution stat or metadata key of any of planNodes.
+func (e *Expr) checkIdents(planNodes []*sppb.PlanNode) error {
+	var unknown *identExpr
+	walkExpr(e.root, func(ident identExpr) {
+		if unknown != nil || slices.Contains(builtinIdents, ident.name) {
+			return
+		}
+		for _, node := range planNodes {
+			if _, ok := node.GetMetadata().GetFields()[ident.name]; ok {
+				return
+			}
+			if _, ok := node.GetExecutionStats().GetFields()[ident.name]; ok && ident.name != "execution_summary" {
+				return
+			}
+		}
+		unknown = &ident
+	})
+	if unknown != nil {
+		return fmt.Errorf("%q: unknown identifier %q at offset %d", e.source, unknown.name, unknown.pos)
+	}
+	return nil
+}
+
+// walkExpr calls fn for every identifier in x in source order.
+func walkExpr(x exprNode, fn func(identExpr)) {
+	switch x := x.(type) {
+	case identExpr:
+		fn(x)
+	case notExpr:
+		walkExpr(x.x, fn)
+	case logicalExpr:
+		walkExpr(x.x, fn)
+		walkExpr(x.y, fn)
+	case compareExpr:
+		walkExpr(x.x, fn)
+		if x.y != nil {
+			walkExpr(x.y, fn)
+		}
+	}
+}
+
+type exprKind int
+
+const (
+	exprMissing exprKind = iota
+	exprNumber
+	exprString
+	exprBool
+)
+
+type exprValue struct {
+	kind exprKind
+	num  float64
+	str  string
+	b    bool
+}
+
+func (v exprValue) truthy() bool {
+	switch v.kind {
+	case exprBool:
+		return v.b
+	case exprNumber:
+		return v.num != 0
+	case exprString:
+		return v.str != ""
+	default:
+		return false
+	}
+}
+
+// number returns v as a number. Strings are converted if they are numeric.
+func (v exprValue) number() (float64, bool) {
+	switch v.kind {
+	case exprNumber:
+		return v.num, true
+	case exprString:
+		f, err := strconv.ParseFloat(v.str, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func (v exprValue) String() string {
+	switch v.kind {
+	case exprNumber:
+		return strconv.FormatFloat(v.num, 'g', -1, 64)
+	case exprBool:
+		return strconv.FormatBool(v.b)
+	default:
+		return v.str
+	}
+}
+
+type exprNode interface {
+	eval(node *TreeNode) exprValue
+}
+
+type literalExpr struct{ value exprValue }
+
+func (e literalExpr) eval(*TreeNode) exprValue { return e.value }
+
+type identExpr struct {
+	name string
+	pos  int
+}
+
+func (e identExpr) eval(node *TreeNode) exprValue {
+	switch e.name {
+	case "display_name":
+		return exprValue{kind: exprString, str: node.planNode.GetDisplayName()}
+	case "title":
+		return exprValue{kind: exprString, str: node.GetTitle()}
+	case "name":
+		return exprValue{kind: exprString, str: node.GetName()}
+	case "index":
+		return exprValue{kind: exprNumber, num: float64(node.planNode.GetIndex())}
+	}
+	if v, ok := node.ExecutionStat(e.name); ok {
+		return exprValue{kind: exprNumber, num: v.Total}
+	}
+	field, ok := node.planNode.GetMetadata().GetFields()[e.name]
+	if !ok {
+		return exprValue{}
+	}
+	switch kind := field.GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		return exprValue{kind: exprNumber, num: kind.NumberValue}
+	case *structpb.Value_BoolValue:
+		return exprValue{kind: exprBool, b: kind.BoolValue}
+	default:
+		return exprValue{kind: exprString, str: fmt.Sprint(field.AsInterface())}
+	}
+}
+
+type notExpr struct{ x exprNode }
+
+func (e notExpr) eval(node *TreeNode) exprValue {
+	return exprValue{kind: exprBool, b: !e.x.eval(node).truthy()}
+}
+
+type logicalExpr struct {
+	and  bool
+	x, y exprNode
+}
+
+func (e logicalExpr) eval(node *TreeNode) exprValue {
+	x := e.x.eval(node).truthy()
+	if x != e.and {
+		return exprValue{kind: exprBool, b: x}
+	}
+	return exprValue{kind: exprBool, b: e.y.eval(node).truthy()}
+}
+
+type compareExpr struct {
+	op   string
+	x, y exprNode
+	re   *regexp.Regexp
+}
+
+func (e compareExpr) eval(node *TreeNode) exprValue {
+	x := e.x.eval(node)
+	if x.kind == exprMissing {
+		return exprValue{kind: exprBool}
+	}
+	if e.re != nil {
+		return exprValue{kind: exprBool, b: e.re.MatchString(x.String())}
+	}
+	y := e.y.eval(node)
+	if y.kind == exprMissing {
+		return exprValue{kind: exprBool}
+	}
+	return exprValue{kind: exprBool, b: compareValues(e.op, x, y)}
+}
+
+func compareValues(op string, x, y exprValue) bool {
+	xn, xok := x.number()
+	yn, yok := y.number()
+	if xok && yok {
+		switch op {
+		case "==":
+			return xn == yn
+		case "!=":
+			return xn != yn
+		case "<":
+			return xn < yn
+		case "<=":
+			return xn <= yn
+		case ">":
+			return xn > yn
+		case ">=":
+			return xn >= yn
+		}
+	}
+	switch op {
+	case "==":
+		return x.String() == y.String()
+	case "!=":
+		return x.String() != y.String()
+	}
+	return false
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value exprValue
+	pos   int
+}
+
+type exprParser struct {
+	source string
+	pos    int
+	tok    token
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%q: %s at offset %d", p.source, fmt.Sprintf(format, args...), p.tok.pos)
+}
+
+var exprOperators = []string{"||", "&&", "==", "!=", "<=", ">=", "=~", "<", ">", "!", "(", ")"}
+
+// next reads the next token into p.tok.
+func (p *exprParser) next() error {
+	for p.pos < len(p.source) {
+		r, size := utf8.DecodeRuneInString(p.source[p.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		p.pos += size
+	}
+	start := p.pos
+	p.tok = token{pos: start}
+	if p.pos >= len(p.source) {
+		return nil
+	}
+
+	rest := p.source[p.pos:]
+	c, size := utf8.DecodeRuneInString(rest)
+	switch {
+	case c == '"' || c == '\'' || c == '`':
+		end := strings.IndexRune(rest[1:], c)
+		if end < 0 {
+			return p.errorf("unterminated %c", c)
+		}
+		text := rest[1 : end+1]
+		p.pos += end + 2
+		if c == '`' {
+			p.tok = token{kind: tokenIdent, text: text, pos: start}
+			return nil
+		}
+		p.tok = token{kind: tokenString, text: rest[:end+2], value: exprValue{kind: exprString, str: text}, pos: start}
+		return nil
+	case c >= '0' && c <= '9' || c == '.':
+		return p.scanNumber()
+	case c == '_' || unicode.IsLetter(c):
+		end := strings.IndexFunc(rest, func(r rune) bool { return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if end < 0 {
+			end = len(rest)
+		}
+		p.pos += end
+		p.tok = token{kind: tokenIdent, text: rest[:end], pos: start}
+		return nil
+	}
+	for _, op := range exprOperators {
+		if strings.HasPrefix(rest, op) {
+			p.pos += len(op)
+			p.tok = token{kind: tokenOperator, text: op, pos: start}
+			return nil
+		}
+	}
+	p.tok.text = rest[:size]
+	return p.errorf("unexpected %q", rest[:size])
+}
+
+// scanNumber reads a number with an optional duration or size unit.
+func (p *exprParser) scanNumber() error {
+	rest := p.source[p.pos:]
+	end := 0
+	for end < len(rest) {
+		c := rest[end]
+		if (c == 'e' || c == 'E') && end+1 < len(rest) && strings.IndexByte("0123456789+-", rest[end+1]) >= 0 {
+			end += 2
+			continue
+		}
+		if c != '.' && (c < '0' || c > '9') {
+			break
+		}
+		end++
+	}
+	number := rest[:end]
+	unitEnd := end
+	for unitEnd < len(rest) {
+		r, size := utf8.DecodeRuneInString(rest[unitEnd:])
+		if !unicode.IsLetter(r) {
+			break
+		}
+		unitEnd += size
+	}
+	unit := rest[end:unitEnd]
+	p.tok = token{kind: tokenNumber, text: rest[:unitEnd], pos: p.pos}
+	p.pos += unitEnd
+
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return p.errorf("invalid number %q", p.tok.text)
+	}
+	switch {
+	case unit == "":
+	case durationUnits[unit] != 0:
+		f *= durationUnits[unit].Seconds()
+	case byteUnits[unit] != 0:
+		f *= byteUnits[unit]
+	case exprSizeUnits[unit] != 0:
+		f *= exprSizeUnits[unit]
+	default:
+		d, err := time.ParseDuration(p.tok.text)
+		if err != nil {
+			return p.errorf("unknown unit %q", unit)
+		}
+		f = d.Seconds()
+	}
+	p.tok.value = exprValue{kind: exprNumber, num: f}
+	return nil
+}
+
+// exprSizeUnits are the short size units accepted in expressions in addition to byteUnits.
+var exprSizeUnits = map[string]float64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	x, err := p.parseAnd()
+	for err == nil && p.tok.kind == tokenOperator && p.tok.text == "||" {
+		var y exprNode
+		if err = p.next(); err != nil {
+			break
+		}
+		if y, err = p.parseAnd(); err == nil {
+			x = logicalExpr{and: false, x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	x, err := p.parseNot()
+	for err == nil && p.tok.kind == tokenOperator && p.tok.text == "&&" {
+		var y exprNode
+		if err = p.next(); err != nil {
+			break
+		}
+		if y, err = p.parseNot(); err == nil {
+			x = logicalExpr{and: true, x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.tok.kind == tokenOperator && p.tok.text == "!" {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	x, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenOperator {
+		return x, nil
+	}
+	op := p.tok.text
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "=~":
+	default:
+		return x, nil
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if op == "=~" {
+		if p.tok.kind != tokenString {
+			return nil, p.errorf("=~ needs a string literal on the right")
+		}
+		re, err := regexp.Compile(p.tok.value.str)
+		if err != nil {
+			return nil, p.errorf("invalid regular expression: %v", err)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		return compareExpr{op: op, x: x, re: re}, nil
+	}
+
+	y, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareExpr{op: op, x: x, y: y}, nil
+}
+
+func (p *exprParser) parseOperand() (exprNode, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokenIdent && (tok.text == "true" || tok.text == "false"):
+		return literalExpr{value: exprValue{kind: exprBool, b: tok.text == "true"}}, p.next()
+	case tok.kind == tokenIdent:
+		return identExpr{name: tok.text, pos: tok.pos}, p.next()
+	case tok.kind == tokenNumber || tok.kind == tokenString:
+		return literalExpr{value: tok.value}, p.next()
+	case tok.kind == tokenOperator && tok.text == "(":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokenOperator || p.tok.text != ")" {
+			return nil, p.errorf("missing )")
+		}
+		return x, p.next()
+	case tok.kind == tokenEOF:
+		return nil, p.errorf("unexpected end of expression")
+	default:
+		return nil, p.errorf("unexpected %q", tok.text)
+	}
+}
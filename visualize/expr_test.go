@@ -0,0 +1,131 @@
+package visualize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExprMatch(t *testing.T) {
+	t.Parallel()
+
+	plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		expr string
+		want []string
+	}{
+		{`scanned_rows > 1e6`, []string{"node29"}},
+		{`scanned_rows >= 1000 && scan_type == "TableScan"`, []string{"node5", "node29"}},
+		{`latency > 1s || display_name == 'Serialize Result'`, []string{"node0", "node18"}},
+		{`latency > 990ms && !(title =~ "Apply$")`, []string{"node18", "node27", "node29"}},
+		{`scan_target =~ "^Song"`, []string{"node29"}},
+		{`index < 3`, []string{"node0", "node1", "node2"}},
+		{"`Number of Batches` == 1", []string{"node0"}},
+		{`call_type == "Local" && subquery_cluster_node != 5`, []string{"node27"}},
+		{"`Full scan` == true || split_ranges_aligned == false", []string{"node3", "node5"}},
+		{`scan_method`, []string{"node5", "node21", "node29"}},
+		{`rows > "x"`, nil},
+		{"index\u3000<\u00a03", []string{"node0", "node1", "node2"}},
+		{`名前 == "node0" || name == "node1"`, []string{"node1"}},
+	} {
+		got := nodeNames(plan.Find(mustCompileExpr(t, tt.expr).Match))
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("Find(%s) mismatch (-want +got):\n%s", tt.expr, diff)
+		}
+	}
+}
+
+func TestCompileExprError(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		expr, want string
+	}{
+		{`latency >`, "unexpected end of expression at offset 9"},
+		{`latency > 1 rows`, `unexpected "rows" at offset 12`},
+		{`(rows > 1`, "missing ) at offset 9"},
+		{`latency > 100parsecs`, `unknown unit "parsecs"`},
+		{`title =~ title`, "=~ needs a string literal on the right"},
+		{`title =~ "("`, "invalid regular expression"},
+		{`title == "Scan`, "unterminated \""},
+		{`rows # 1`, `unexpected "#" at offset 5`},
+		{`rows → 1`, `unexpected "→" at offset 5`},
+		{`latency > 1msé`, `unknown unit "msé"`},
+	} {
+		_, err := CompileExpr(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("CompileExpr(%s) error = %v, want it to contain %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+func TestBuildPlanOnlyAndHighlight(t *testing.T) {
+	t.Parallel()
+
+	plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{
+		Only:      `scan_type == "TableScan"`,
+		Highlight: `scanned_rows > 1e6`,
+	})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+	want := []string{
+		"node0 -[Input]-> node1",
+		"node1 -[]-> node2",
+		"node2 -[]-> node3",
+		"node3 -[].-> node4",
+		"node4 -[]-> node5",
+		"node0 -[Map].-> node18",
+		"node18 -[]-> node19",
+		"node19 -[Map]-> node27",
+		"node27 -[]-> node28",
+		"node28 -[]-> node29",
+	}
+	if diff := cmp.Diff(want, linkLines(plan.Root, plan.Root.Children)); diff != "" {
+		t.Errorf("tree mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"node29"}, nodeNames(plan.Find((*TreeNode).IsHighlighted))); diff != "" {
+		t.Errorf("highlighted nodes mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := buildTestPlan(t, "dca_profile.json", BuildOptions{Only: "rows >"}); err == nil || !strings.Contains(err.Error(), "invalid only expression") {
+		t.Errorf("BuildPlan() error = %v, want invalid only expression", err)
+	}
+}
+
+func TestBuildPlanUnknownIdentifier(t *testing.T) {
+	t.Parallel()
+
+	for _, source := range []string{"name == \"node3\"", "scanned_rows > 1000", "scan_target =~ \"^Singers\"", "`Number of Batches` > 0"} {
+		if _, err := buildTestPlan(t, "dca_profile.json", BuildOptions{Highlight: source}); err != nil {
+			t.Errorf("BuildPlan(Highlight: %s) error = %v", source, err)
+		}
+	}
+
+	_, err := buildTestPlan(t, "dca_profile.json", BuildOptions{Highlight: "latency > 1s || scaned_rows > 1000"})
+	if want := `invalid highlight expression: "latency > 1s || scaned_rows > 1000": unknown identifier "scaned_rows" at offset 16`; err == nil || err.Error() != want {
+		t.Errorf("BuildPlan() error = %v, want %s", err, want)
+	}
+}
+
+func mustCompileExpr(t *testing.T, source string) *Expr {
+	t.Helper()
+	expr, err := CompileExpr(source)
+	if err != nil {
+		t.Fatalf("CompileExpr(%s) error = %v", source, err)
+	}
+	return expr
+}
+
+func nodeNames(nodes []*TreeNode) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.GetName())
+	}
+	return names
+}
@@ -0,0 +1,103 @@
+package visualize
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// buildTestPlan builds the plan of the ResultSet in testdata/name.
+func buildTestPlan(t *testing.T, name string, opts BuildOptions) (*Plan, error) {
+	t.Helper()
+
+	b, err := testdataFS.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	var rs sppb.ResultSet
+	if err := protojson.Unmarshal(b, &rs); err != nil {
+		t.Fatalf("unmarshal %s: %v", name, err)
+	}
+	return BuildPlan(rs.GetMetadata().GetRowType(), rs.GetStats(), opts)
+}
+
+// mustBuildTestPlan is buildTestPlan for options that must be valid.
+func mustBuildTestPlan(t *testing.T, name string, opts BuildOptions) *Plan {
+	t.Helper()
+
+	plan, err := buildTestPlan(t, name, opts)
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+	return plan
+}
+
+// testStruct returns fields as a Struct, such as the execution stats of a plan node
+// or the total and unit of one stat.
+func testStruct(t *testing.T, fields map[string]any) *structpb.Struct {
+	t.Helper()
+
+	s, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatalf("NewStruct() error = %v", err)
+	}
+	return s
+}
+
+// durationStats returns execution stats with the latency and cpu_time totals in unit.
+// An empty total omits the stat.
+func durationStats(t *testing.T, unit, latency, cpuTime string) *structpb.Struct {
+	t.Helper()
+
+	fields := map[string]any{}
+	if latency != "" {
+		fields["latency"] = map[string]any{"total": latency, "unit": unit}
+	}
+	if cpuTime != "" {
+		fields["cpu_time"] = map[string]any{"total": cpuTime, "unit": unit}
+	}
+	return testStruct(t, fields)
+}
+
+// linkLines returns "parent -[type]-> child" lines for links from node and, in depth-first order,
+// for the child and expression links below them. Remote links are drawn as ".->" and expression
+// links as "..>"; operators merged into a box are joined by "+", and placeholders are followed
+// by their display name in parentheses.
+func linkLines(node *TreeNode, links []*Link) []string {
+	var lines []string
+	for _, link := range links {
+		arrow := map[EdgeStyle]string{EdgeStyleSolid: "->", EdgeStyleDashed: ".->", EdgeStyleDotted: "..>"}[link.Style]
+		lines = append(lines, edgeLine(node, link.ChildType, arrow, link.ChildNode))
+		lines = append(lines, linkLines(link.ChildNode, link.ChildNode.Children)...)
+		lines = append(lines, linkLines(link.ChildNode, link.ChildNode.Expressions)...)
+	}
+	return lines
+}
+
+// flowLines returns "from -[variables]~> to" lines for flows.
+func flowLines(flows []VariableFlow) []string {
+	var lines []string
+	for _, flow := range flows {
+		lines = append(lines, edgeLine(flow.From, strings.Join(flow.Variables, " "), "~>", flow.To))
+	}
+	return lines
+}
+
+func edgeLine(from *TreeNode, label, arrow string, to *TreeNode) string {
+	return fmt.Sprintf("%s -[%s]%s %s", boxShape(from), label, arrow, boxShape(to))
+}
+
+func boxShape(node *TreeNode) string {
+	names := []string{node.GetName()}
+	for _, merged := range node.Merged {
+		names = append(names, merged.GetName())
+	}
+	if node.OmittedOperators() > 0 {
+		names[0] += "(" + node.PlanNode().GetDisplayName() + ")"
+	}
+	return strings.Join(names, "+")
+}
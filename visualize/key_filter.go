@@ -0,0 +1,97 @@
+package visualize
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// executionSummaryKeyPrefix prefixes the keys of execution summary lines when they are
+// matched against BuildOptions.ShowStats and BuildOptions.HideStats.
+const executionSummaryKeyPrefix = "execution_summary."
+
+// keyRegexps caches the compiled regular expressions of key patterns.
+var keyRegexps sync.Map
+
+// keyPatternRegexp returns the regular expression of a "/regexp/" key pattern,
+// or nil if pattern is a glob.
+func keyPatternRegexp(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) < 2 || !strings.HasPrefix(pattern, "/") || !strings.HasSuffix(pattern, "/") {
+		return nil, nil
+	}
+	if re, ok := keyRegexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := keyRegexps.LoadOrStore(pattern, re)
+	return actual.(*regexp.Regexp), nil
+}
+
+// validateKeyPatterns reports the first malformed pattern of the option name.
+func validateKeyPatterns(name string, patterns []string) error {
+	for _, pattern := range patterns {
+		re, err := keyPatternRegexp(pattern)
+		if err == nil && re == nil {
+			_, err = path.Match(pattern, "")
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s pattern %q: %w", name, pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesKeyPattern reports whether key matches one of patterns. A pattern is a path.Match
+// glob such as "scan_*", or a regular expression between slashes such as "/^(rows|latency)$/".
+func matchesKeyPattern(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if re, _ := keyPatternRegexp(pattern); re != nil {
+			if re.MatchString(key) {
+				return true
+			}
+		} else if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// keyVisible reports whether key is in the allow-list show, if it is not empty,
+// and not in the deny-list hide.
+func keyVisible(key string, show, hide []string) bool {
+	if len(show) > 0 && !matchesKeyPattern(show, key) {
+		return false
+	}
+	return !matchesKeyPattern(hide, key)
+}
+
+func (o BuildOptions) metadataVisible(key string) bool {
+	return keyVisible(key, o.ShowMetadata, o.HideMetadata)
+}
+
+func (o BuildOptions) statVisible(key string) bool {
+	return keyVisible(key, o.ShowStats, o.HideStats)
+}
+
+// validateKeyFilters reports a malformed pattern in the metadata and stat filters of o.
+func (o BuildOptions) validateKeyFilters() error {
+	for _, f := range []struct {
+		name     string
+		patterns []string
+	}{
+		{"show metadata", o.ShowMetadata},
+		{"hide metadata", o.HideMetadata},
+		{"show stats", o.ShowStats},
+		{"hide stats", o.HideStats},
+	} {
+		if err := validateKeyPatterns(f.name, f.patterns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
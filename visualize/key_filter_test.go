@@ -0,0 +1,71 @@
+package visualize
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestKeyVisible(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		key        string
+		show, hide []string
+		want       bool
+	}{
+		{"scan_type", nil, nil, true},
+		{"scan_type", nil, []string{"scan_type"}, false},
+		{"scan_method", nil, []string{"scan_*"}, false},
+		{"Disk Usage (KBytes)", nil, []string{"/KBytes\\)$/"}, false},
+		{"latency", []string{"/^(rows|latency)$/"}, nil, true},
+		{"latency (self)", []string{"/^(rows|latency)$/"}, nil, false},
+		{"latency (self)", []string{"latency*"}, []string{"* (self)"}, false},
+		{"rows", []string{"latency"}, nil, false},
+		{"/", nil, []string{"/"}, false},
+	} {
+		if got := keyVisible(tt.key, tt.show, tt.hide); got != tt.want {
+			t.Errorf("keyVisible(%q, %q, %q) = %v, want %v", tt.key, tt.show, tt.hide, got, tt.want)
+		}
+	}
+}
+
+func TestKeyFilters(t *testing.T) {
+	t.Parallel()
+
+	plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{
+		Metadata:         true,
+		ExecutionStats:   true,
+		ExecutionSummary: true,
+		SelfTime:         true,
+		ShowMetadata:     []string{"/^(distribution_table|scan_.*)$/", "split_*"},
+		HideMetadata:     []string{"scan_method", "split_ranges_aligned"},
+		ShowStats:        []string{"latency*", "execution_summary.num_*"},
+		HideStats:        []string{"execution_summary.num_checkpoints"},
+	})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+
+	if diff := cmp.Diff(map[string]string{"distribution_table": "Singers"}, plan.Node(3).GetMetadata(plan.Build)); diff != "" {
+		t.Errorf("GetMetadata() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{}, plan.Node(5).GetMetadata(plan.Build)); diff != "" {
+		t.Errorf("GetMetadata() mismatch (-want +got):\n%s", diff)
+	}
+	if keys := slices.Sorted(maps.Keys(plan.Node(5).GetStats(plan.Build))); !slices.Equal(keys, []string{"latency", "latency (self)"}) {
+		t.Errorf("GetStats() keys = %q, want latency and its self time", keys)
+	}
+
+	summary := plan.Root.GetExecutionSummary(plan.Build)
+	if !strings.Contains(summary, "num_executions") || strings.Contains(summary, "num_checkpoints") || strings.Contains(summary, "checkpoint_time") {
+		t.Errorf("GetExecutionSummary() = %q, want only num_executions", summary)
+	}
+
+	if _, err := buildTestPlan(t, "dca_profile.json", BuildOptions{HideStats: []string{"/(/"}}); err == nil || !strings.Contains(err.Error(), "invalid hide stats pattern") {
+		t.Errorf("BuildPlan() error = %v, want invalid hide stats pattern", err)
+	}
+}
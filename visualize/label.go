@@ -0,0 +1,176 @@
+package visualize
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// NodeLabel is the renderer-agnostic content of an operator label, as selected by BuildOptions.
+// Strings are not escaped; each renderer formats the label for its backend.
+type NodeLabel struct {
+	// Name is the node ID, e.g. "node3".
+	Name                string
+	Title               string
+	ShortRepresentation string
+	ScanInfo            string
+	// Schema describes the scan target with BuildOptions.Schema.
+	Schema            []string
+	SerializeResult   []string
+	NonVarScalarLinks []string
+	// Metadata is sorted by key.
+	Metadata       []KeyValue
+	VarScalarLinks []string
+	// Stats are sorted by key.
+	Stats            []KeyValue
+	ExecutionSummary []string
+	// Annotations are not part of Sections; the built-in renderers add them below the label.
+	Annotations []Annotation
+	// Truncated holds the full text of the items that BuildOptions.MaxLines shortened.
+	// It is not part of Sections; Graphviz and Mermaid show it in the tooltip of the node.
+	Truncated []string
+}
+
+// KeyValue is a metadata entry or an execution stat of a NodeLabel.
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// SectionKind identifies the content of a LabelSection.
+type SectionKind int
+
+const (
+	SectionTitle SectionKind = iota
+	SectionRepresentation
+	SectionScan
+	SectionSchema
+	SectionSerializeResult
+	SectionScalarLinks
+	SectionMetadata
+	SectionVariables
+	SectionStats
+	SectionSummary
+)
+
+var sectionKindNames = map[SectionKind]string{
+	SectionTitle:           "title",
+	SectionRepresentation:  "representation",
+	SectionScan:            "scan",
+	SectionSchema:          "schema",
+	SectionSerializeResult: "serialize result",
+	SectionScalarLinks:     "scalar links",
+	SectionMetadata:        "metadata",
+	SectionVariables:       "variables",
+	SectionStats:           "stats",
+	SectionSummary:         "summary",
+}
+
+func (k SectionKind) String() string {
+	return sectionKindNames[k]
+}
+
+// LabelSection is a part of a NodeLabel. Metadata and stats sections hold Entries,
+// the others hold Lines.
+type LabelSection struct {
+	Kind    SectionKind
+	Lines   []string
+	Entries []KeyValue
+}
+
+// Sections returns the non-empty sections of the label in display order.
+func (l NodeLabel) Sections() []LabelSection {
+	var sections []LabelSection
+	addLines := func(kind SectionKind, lines ...string) {
+		lines = slices.DeleteFunc(slices.Clone(lines), func(line string) bool { return line == "" })
+		if len(lines) > 0 {
+			sections = append(sections, LabelSection{Kind: kind, Lines: lines})
+		}
+	}
+	addEntries := func(kind SectionKind, entries []KeyValue) {
+		if len(entries) > 0 {
+			sections = append(sections, LabelSection{Kind: kind, Entries: entries})
+		}
+	}
+
+	addLines(SectionTitle, l.Title)
+	addLines(SectionRepresentation, l.ShortRepresentation)
+	addLines(SectionScan, l.ScanInfo)
+	addLines(SectionSchema, l.Schema...)
+	addLines(SectionSerializeResult, l.SerializeResult...)
+	addLines(SectionScalarLinks, l.NonVarScalarLinks...)
+	addEntries(SectionMetadata, l.Metadata)
+	addLines(SectionVariables, l.VarScalarLinks...)
+	addEntries(SectionStats, l.Stats)
+	addLines(SectionSummary, l.ExecutionSummary...)
+	return sections
+}
+
+// Label gathers the content of the operator that param selects for display.
+// Both the Mermaid and the Graphviz labels are laid out from it.
+func (n *TreeNode) Label(param BuildOptions, rowType *sppb.StructType) NodeLabel {
+	label := NodeLabel{
+		Name:                n.GetName(),
+		Title:               n.GetTitle(),
+		ShortRepresentation: n.GetShortRepresentation(),
+		ScanInfo:            n.GetScanInfoOutput(param),
+		Schema:              n.GetSchemaOutput(param),
+		Stats:               sortedKeyValues(n.GetStats(param)),
+		ExecutionSummary:    splitLines(n.GetExecutionSummary(param)),
+		Annotations:         n.Annotations,
+	}
+
+	wrapper := newLabelWrapper(param)
+	if param.Metadata {
+		label.Metadata = sortedKeyValues(n.GetMetadata(param))
+		for i := range label.Metadata {
+			label.Metadata[i].Value = wrapper.text(label.Metadata[i].Value)
+		}
+	}
+	if param.SerializeResult {
+		label.SerializeResult = splitLines(n.GetSerializeResultOutput(rowType))
+	}
+	if param.NonVariableScalar {
+		label.NonVarScalarLinks = wrapper.lines(splitLines(n.GetNonVarScalarLinksOutput()))
+	}
+	if param.VariableScalar {
+		label.VarScalarLinks = wrapper.lines(splitLines(n.GetVarScalarLinksOutput()))
+	}
+	label.Truncated = wrapper.truncated
+
+	// A Scan whose short representation already names the scan target would print it twice.
+	if n.planNode.GetDisplayName() == "Scan" && label.ScanInfo != "" && label.ScanInfo == label.ShortRepresentation {
+		label.ScanInfo = ""
+	}
+	return label
+}
+
+// splitLines returns the non-empty lines of s.
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func sortedKeyValues(m map[string]string) []KeyValue {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]KeyValue, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, KeyValue{Key: k, Value: m[k]})
+	}
+	return result
+}
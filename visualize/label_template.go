@@ -0,0 +1,125 @@
+package visualize
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// DefaultMermaidLabelTemplate lays out Mermaid labels. Empty lines of the output are dropped.
+const DefaultMermaidLabelTemplate = `{{with .Title}}{{markup "b" (escape .)}}{{end}}
+{{with .ShortRepresentation}}{{escape .}}{{end}}
+{{with .ScanInfo}}{{escape .}}{{end}}
+{{range .Schema}}{{escape .}}
+{{end}}{{range .SerializeResult}}{{escape .}}
+{{end}}{{range .NonVarScalarLinks}}{{escape .}}
+{{end}}{{range .Metadata}}{{escape .Key}}: {{escape .Value}}
+{{end}}{{range .VarScalarLinks}}{{escape .}}
+{{end}}{{range .Stats}}{{markup "i" (printf "%s: %s" (escape .Key) (escape .Value))}}
+{{end}}{{with .ExecutionSummary}}<i>{{range $i, $line := .}}{{if $i}}{{"\n"}}{{end}}{{escape $line}}{{end}}</i>{{end}}`
+
+// DefaultGraphvizLabelTemplate lays out Graphviz HTML-like labels. The output is used as is.
+const DefaultGraphvizLabelTemplate = `{{define "body" -}}
+{{with .ShortRepresentation}}{{escape .}}{{"\n"}}{{end -}}
+{{with .ScanInfo}}{{escape .}}{{"\n"}}{{end -}}
+{{range .Schema}}{{escape .}}{{"\n"}}{{end -}}
+{{range .SerializeResult}}{{escape .}}{{"\n"}}{{end -}}
+{{range .NonVarScalarLinks}}{{escape .}}{{"\n"}}{{end -}}
+{{range .Metadata}}{{escape .Key}}={{escape .Value}}{{"\n"}}{{end -}}
+{{range .VarScalarLinks}}{{escape .}}{{"\n"}}{{end -}}
+{{end}}{{define "stats" -}}
+{{range .Stats}}{{escape .Key}}: {{escape .Value}}{{"\n"}}{{end -}}
+{{range .ExecutionSummary}}{{escape .}}{{"\n"}}{{end -}}
+{{end -}}
+{{$body := print (leftAligned (include "body" .)) (markup "i" (leftAligned (include "stats" .))) -}}
+{{if and .Title $body}}<b>{{.Title}}</b><br align="CENTER"/>{{$body}}{{else if .Title}}<b>{{.Title}}</b>{{else}}{{$body}}{{end}}`
+
+// labelBackend is the target format of a label template.
+type labelBackend string
+
+const (
+	labelBackendMermaid  labelBackend = "mermaid"
+	labelBackendGraphviz labelBackend = "graphviz"
+)
+
+type labelTemplateKey struct {
+	backend labelBackend
+	source  string
+}
+
+// labelTemplates caches parsed label templates by backend and source.
+var labelTemplates sync.Map
+
+// parseLabelTemplate parses source for backend, or the default template if source is empty.
+//
+// Besides the text/template builtins, templates can call:
+//   - escape: escapes a string for the backend
+//   - markup: wraps a non-empty string in an element, e.g. {{markup "b" .Title}}
+//   - leftAligned: appends a left-aligned Graphviz line break to every line
+//   - include: executes a named template and returns its output without the trailing newline
+func parseLabelTemplate(backend labelBackend, source string) (*template.Template, error) {
+	if source == "" {
+		switch backend {
+		case labelBackendMermaid:
+			source = DefaultMermaidLabelTemplate
+		case labelBackendGraphviz:
+			source = DefaultGraphvizLabelTemplate
+		}
+	}
+
+	key := labelTemplateKey{backend, source}
+	if tmpl, ok := labelTemplates.Load(key); ok {
+		return tmpl.(*template.Template), nil
+	}
+
+	escape := escapeMermaidLabelContent
+	if backend == labelBackendGraphviz {
+		escape = escapeGraphvizHTMLLabelContent
+	}
+	tmpl := template.New(string(backend))
+	tmpl.Funcs(template.FuncMap{
+		"escape":      escape,
+		"markup":      markupIfNotEmpty,
+		"leftAligned": toLeftAlignedText,
+		"include": func(name string, data any) (string, error) {
+			var sb strings.Builder
+			if err := tmpl.ExecuteTemplate(&sb, name, data); err != nil {
+				return "", err
+			}
+			return strings.TrimSuffix(sb.String(), "\n"), nil
+		},
+	})
+	if _, err := tmpl.Parse(source); err != nil {
+		return nil, fmt.Errorf("invalid %s label template: %w", backend, err)
+	}
+
+	actual, _ := labelTemplates.LoadOrStore(key, tmpl)
+	return actual.(*template.Template), nil
+}
+
+// validateLabelTemplates reports a syntax error in the label templates of param.
+func validateLabelTemplates(param BuildOptions) error {
+	if _, err := parseLabelTemplate(labelBackendMermaid, param.MermaidLabelTemplate); err != nil {
+		return err
+	}
+	_, err := parseLabelTemplate(labelBackendGraphviz, param.GraphvizLabelTemplate)
+	return err
+}
+
+// executeLabelTemplate lays out label with the template of backend.
+// Errors are rendered in place of the label, so that a broken template is visible in the diagram.
+func executeLabelTemplate(backend labelBackend, source string, label NodeLabel) string {
+	tmpl, err := parseLabelTemplate(backend, source)
+	if err == nil {
+		var sb strings.Builder
+		if err = tmpl.Execute(&sb, label); err == nil {
+			return sb.String()
+		}
+	}
+	if backend == labelBackendMermaid {
+		return escapeMermaidLabelContent(err.Error())
+	}
+	return html.EscapeString(err.Error())
+}
@@ -0,0 +1,62 @@
+package visualize
+
+import (
+	"strings"
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestLabelTemplates(t *testing.T) {
+	t.Parallel()
+
+	metadata, _ := structpb.NewStruct(map[string]any{"scan_type": "TableScan", "scan_target": "Songs", "b": "<2>", "a": "1"})
+	node := &TreeNode{planNode: &sppb.PlanNode{Index: 4, DisplayName: "Scan", Metadata: metadata}}
+
+	tests := []struct {
+		name        string
+		param       BuildOptions
+		wantHTML    string
+		wantMermaid string
+	}{
+		{
+			name:        "custom order and subset",
+			param:       BuildOptions{Metadata: true, MermaidLabelTemplate: "{{.ScanInfo}}\n\n{{range .Metadata}}{{.Key}}={{escape .Value}}\n{{end}}", GraphvizLabelTemplate: `{{.Name}}: <b>{{.Title}}</b>{{range .Metadata}}<br/>{{.Key}}={{escape .Value}}{{end}}`},
+			wantHTML:    `node4: <b>Table Scan</b><br/>a=1<br/>b=&lt;2&gt;`,
+			wantMermaid: "Table: Songs\na=1\nb=&lt;2&gt;",
+		},
+		{
+			name:        "empty output falls back to the name",
+			param:       BuildOptions{MermaidLabelTemplate: "{{/* nothing */}}", GraphvizLabelTemplate: " "},
+			wantHTML:    " ",
+			wantMermaid: "node4",
+		},
+		{
+			name:        "execution error is shown in the label",
+			param:       BuildOptions{MermaidLabelTemplate: "{{.Unknown}}", GraphvizLabelTemplate: `{{include "missing" .}}`},
+			wantHTML:    "template: graphviz:1:2: executing &#34;graphviz&#34; at &lt;include &#34;missing&#34; .&gt;: error calling include: template: no template &#34;missing&#34; associated with template &#34;graphviz&#34;",
+			wantMermaid: "template:&nbsp;mermaid:1:2:&nbsp;executing&nbsp;&quot;mermaid&quot;&nbsp;at&nbsp;&lt;.Unknown&gt;:&nbsp;can't&nbsp;evaluate&nbsp;field&nbsp;Unknown&nbsp;in&nbsp;type&nbsp;visualize.NodeLabel",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := node.HTML(tt.param, nil); got != tt.wantHTML {
+				t.Errorf("HTML() = %q, want %q", got, tt.wantHTML)
+			}
+			if got := node.MermaidLabel(tt.param, nil); got != tt.wantMermaid {
+				t.Errorf("MermaidLabel() = %q, want %q", got, tt.wantMermaid)
+			}
+		})
+	}
+}
+
+func TestBuildPlanInvalidLabelTemplate(t *testing.T) {
+	t.Parallel()
+
+	if _, err := buildTestPlan(t, "dca_profile.json", BuildOptions{GraphvizLabelTemplate: "{{"}); err == nil || !strings.Contains(err.Error(), "graphviz label template") {
+		t.Errorf("BuildPlan() with invalid template error = %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package visualize
+
+import (
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestTreeNodeLabel(t *testing.T) {
+	t.Parallel()
+
+	metadata, _ := structpb.NewStruct(map[string]any{"scan_type": "TableScan", "scan_target": "Songs", "scan_method": "Row", "execution_method": "Row"})
+	node := &TreeNode{
+		planNode: &sppb.PlanNode{
+			Index:          4,
+			DisplayName:    "Scan",
+			Metadata:       metadata,
+			ExecutionStats: durationStats(t, "msecs", "5", ""),
+		},
+		Annotations: []Annotation{{Text: "note"}},
+	}
+
+	label := node.Label(BuildOptions{Metadata: true, ExecutionStats: true}, nil)
+	want := NodeLabel{
+		Name:     "node4",
+		Title:    "Table Scan",
+		ScanInfo: "Table: Songs",
+		Metadata: []KeyValue{
+			{Key: "execution_method", Value: "Row"},
+			{Key: "scan_method", Value: "Row"},
+		},
+		Stats:       []KeyValue{{Key: "latency", Value: "5 msecs"}},
+		Annotations: []Annotation{{Text: "note"}},
+	}
+	if diff := cmp.Diff(want, label); diff != "" {
+		t.Errorf("Label() mismatch (-want +got):\n%s", diff)
+	}
+
+	wantSections := []LabelSection{
+		{Kind: SectionTitle, Lines: []string{"Table Scan"}},
+		{Kind: SectionScan, Lines: []string{"Table: Songs"}},
+		{Kind: SectionMetadata, Entries: want.Metadata},
+		{Kind: SectionStats, Entries: want.Stats},
+	}
+	if diff := cmp.Diff(wantSections, label.Sections()); diff != "" {
+		t.Errorf("Sections() mismatch (-want +got):\n%s", diff)
+	}
+	if got := SectionSerializeResult.String(); got != "serialize result" {
+		t.Errorf("SectionSerializeResult.String() = %q", got)
+	}
+}
@@ -0,0 +1,97 @@
+package visualize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// queryTotals holds the query-wide totals that stats are compared with for BuildOptions.PercentOfQuery.
+type queryTotals struct {
+	elapsed time.Duration
+	cpuTime time.Duration
+}
+
+// percentStats lists the stats annotated with their share of the query, the query total
+// they are compared with, and how the total is named in the annotation.
+var percentStats = []struct {
+	key   string
+	total func(*queryTotals) time.Duration
+	label string
+}{
+	{"latency", func(q *queryTotals) time.Duration { return q.elapsed }, "elapsed"},
+	{"cpu_time", func(q *queryTotals) time.Duration { return q.cpuTime }, "query cpu_time"},
+}
+
+// newQueryTotals takes elapsed_time and cpu_time from the query stats, falling back to the
+// latency and cpu_time of the root operator when the query stats lack them.
+func newQueryTotals(queryStats *sppb.ResultSetStats, root *sppb.PlanNode) *queryTotals {
+	fields := queryStats.GetQueryStats().GetFields()
+	totals := &queryTotals{}
+
+	var ok bool
+	if totals.elapsed, ok = parseDurationString(fields["elapsed_time"].GetStringValue()); !ok {
+		totals.elapsed, _ = statDuration(root, "latency")
+	}
+	if totals.cpuTime, ok = parseDurationString(fields["cpu_time"].GetStringValue()); !ok {
+		totals.cpuTime, _ = statDuration(root, "cpu_time")
+	}
+	return totals
+}
+
+// parseDurationString parses query stats durations such as "1.09 secs".
+func parseDurationString(s string) (time.Duration, bool) {
+	number, unitName, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		return 0, false
+	}
+	unit, ok := durationUnits[unitName]
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(f * float64(unit)), true
+}
+
+// setQueryTotals makes totals available to node and its descendants.
+func setQueryTotals(node *TreeNode, totals *queryTotals) {
+	node.queryTotals = totals
+	for _, link := range node.PlanChildren() {
+		setQueryTotals(link.ChildNode, totals)
+	}
+}
+
+// appendPercentOfQuery appends the share of the query total to the latency and cpu_time lines
+// of statsMap, including self time lines, e.g. "78.03 msecs (7.2% of elapsed)".
+func (n *TreeNode) appendPercentOfQuery(statsMap map[string]string) {
+	if n.queryTotals == nil {
+		return
+	}
+	for _, ps := range percentStats {
+		total := ps.total(n.queryTotals)
+		if total <= 0 {
+			continue
+		}
+		if d, ok := statDuration(n.statsNode(), ps.key); ok {
+			if line, ok := statsMap[ps.key]; ok {
+				statsMap[ps.key] = fmt.Sprintf("%s (%s of %s)", line, formatPercent(d, total), ps.label)
+			}
+		}
+		selfKey := ps.key + " (self)"
+		if d, ok := n.SelfTime(ps.key); ok {
+			if line, ok := statsMap[selfKey]; ok {
+				statsMap[selfKey] = fmt.Sprintf("%s (%s of %s)", line, formatPercent(d, total), ps.label)
+			}
+		}
+	}
+}
+
+func formatPercent(d, total time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(total)*100, 'f', 1, 64) + "%"
+}
@@ -0,0 +1,68 @@
+package visualize
+
+import (
+	"testing"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetStats_percentOfQuery(t *testing.T) {
+	t.Parallel()
+
+	plan := mustBuildTestPlan(t, "dca_profile.json", BuildOptions{})
+	node := plan.Root.Children[0].ChildNode.Children[0].ChildNode // node2 Compute Struct
+
+	got := node.GetStats(BuildOptions{ExecutionStats: true, SelfTime: true, PercentOfQuery: true})
+	want := map[string]string{
+		"cpu_time":        "31.2 msecs (8.2% of query cpu_time)",
+		"cpu_time (self)": "1 msecs (0.3% of query cpu_time)",
+		"latency":         "79.04 msecs (7.3% of elapsed)",
+		"latency (self)":  "1.01 msecs (0.1% of elapsed)",
+		"rows":            "1000 rows",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetStats() mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := node.GetStats(BuildOptions{ExecutionStats: true})["latency"]; got != "79.04 msecs" {
+		t.Errorf(`GetStats() without PercentOfQuery ["latency"] = %q`, got)
+	}
+}
+
+func TestGetStats_percentOfQueryFallsBackToRoot(t *testing.T) {
+	t.Parallel()
+
+	plan, err := BuildPlan(nil, &sppb.ResultSetStats{QueryPlan: &sppb.QueryPlan{PlanNodes: []*sppb.PlanNode{
+		{Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Union All", ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 1}}, ExecutionStats: durationStats(t, "msecs", "200", "")},
+		{Index: 1, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Scan", ExecutionStats: durationStats(t, "msecs", "50", "")},
+	}}}, BuildOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+
+	got := plan.Root.Children[0].ChildNode.GetStats(BuildOptions{ExecutionStats: true, PercentOfQuery: true})
+	if got["latency"] != "50 msecs (25.0% of elapsed)" {
+		t.Errorf(`GetStats()["latency"] = %q, want "50 msecs (25.0%% of elapsed)"`, got["latency"])
+	}
+}
+
+func TestParseDurationString(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]time.Duration{
+		"1.09 secs":    1090 * time.Millisecond,
+		"381.16 msecs": 381160 * time.Microsecond,
+	}
+	for in, want := range tests {
+		if got, ok := parseDurationString(in); !ok || got != want {
+			t.Errorf("parseDurationString(%q) = %v, %v; want %v", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "0", "1 parsecs", "x secs"} {
+		if _, ok := parseDurationString(in); ok {
+			t.Errorf("parseDurationString(%q) ok = true", in)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package visualize
+
+import (
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan"
+)
+
+// buildExpressions returns the scalar expression trees below planNode that the operator tree
+// hides, such as Function, Reference, Constant and Parameter nodes, linked with EdgeStyleDotted.
+// Scalar subqueries stay in the operator tree and are not followed.
+func buildExpressions(qp *spannerplan.QueryPlan, planNode *sppb.PlanNode) []*Link {
+	var links []*Link
+	for _, cl := range planNode.GetChildLinks() {
+		if qp.IsVisible(cl) {
+			continue
+		}
+		child := qp.GetNodeByChildLink(cl)
+		links = append(links, &Link{
+			ChildType: expressionLinkType(cl),
+			Style:     EdgeStyleDotted,
+			ChildNode: &TreeNode{planNode: child, Expressions: buildExpressions(qp, child)},
+		})
+	}
+	return links
+}
+
+// expressionLinkType labels a scalar child link with its type, such as "Residual Condition",
+// or with the variable it defines, such as "$SingerId". Operands of functions have neither.
+func expressionLinkType(cl *sppb.PlanNode_ChildLink) string {
+	switch {
+	case cl.GetType() != "":
+		return cl.GetType()
+	case cl.GetVariable() != "":
+		return "$" + cl.GetVariable()
+	default:
+		return ""
+	}
+}
+
+// ExpressionLinks returns the scalar expressions attached to the node's box: its own
+// Expressions followed by those of the operators merged into it.
+func (n *TreeNode) ExpressionLinks() []*Link {
+	links := n.Expressions
+	for _, merged := range n.Merged {
+		links = append(links[:len(links):len(links)], merged.ExpressionLinks()...)
+	}
+	return links
+}
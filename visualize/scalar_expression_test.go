@@ -0,0 +1,64 @@
+package visualize
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildPlanScalarExpressions(t *testing.T) {
+	t.Parallel()
+
+	plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{ScalarExpressions: true})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+
+	filterScan := plan.Node(28)
+	want := []string{
+		"node28 -[Residual Condition]..> node43",
+		"node43 -[]..> node39",
+		"node39 -[]..> node40",
+		"node39 -[]..> node41",
+	}
+	if diff := cmp.Diff(want, linkLines(filterScan, filterScan.Expressions)); diff != "" {
+		t.Errorf("Expressions mismatch (-want +got):\n%s", diff)
+	}
+	if got := filterScan.Expressions[0].ChildNode.GetTitle(); got != "Function" {
+		t.Errorf("Residual Condition title = %q, want Function", got)
+	}
+
+	if got := linkLines(plan.Node(1), plan.Node(1).Expressions); !cmp.Equal(got, []string{"node1 -[$v2.Batch]..> node17"}) {
+		t.Errorf("Create Batch expressions = %q", got)
+	}
+	if plan.Node(36) != nil {
+		t.Errorf("Node(36) = %v, want scalar nodes outside of Walk", plan.Node(36))
+	}
+
+	plain, err := buildTestPlan(t, "dca_profile.json", BuildOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+	if got := plain.Node(28).Expressions; got != nil {
+		t.Errorf("Expressions = %v without ScalarExpressions", got)
+	}
+}
+
+func TestTreeNodeExpressionLinksCompact(t *testing.T) {
+	t.Parallel()
+
+	plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{ScalarExpressions: true, Compact: true})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+
+	// Local Distributed Union, Filter Scan and Table Scan are drawn as one box.
+	var names []string
+	for _, link := range plan.Node(27).ExpressionLinks() {
+		names = append(names, link.ChildType)
+	}
+	want := []string{"Residual Condition", "$SingerId_1", "$AlbumId", "$TrackId", "$SongName", "$Duration", "$SongGenre", "Seek Condition"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("ExpressionLinks() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,24 @@
+package visualize
+
+// Schema describes the scan targets of a database for BuildOptions.Schema.
+// *schema.Schema, which is loaded from DDL, implements it.
+type Schema interface {
+	// DescribeScanTarget returns label lines about target, the scan_target of a scan
+	// with scan_type scanType such as "TableScan" or "IndexScan", or nil if it is unknown.
+	DescribeScanTarget(scanType, target string) []string
+}
+
+// GetSchemaOutput returns the lines that param.Schema gives for the scan target of a scan operator.
+// It returns nil for other operators and with HideScanTarget.
+func (n *TreeNode) GetSchemaOutput(param BuildOptions) []string {
+	if param.Schema == nil || param.HideScanTarget {
+		return nil
+	}
+
+	metadataFields := n.planNode.GetMetadata().GetFields()
+	scanType := metadataFields["scan_type"].GetStringValue()
+	if scanType == "" {
+		return nil
+	}
+	return param.Schema.DescribeScanTarget(scanType, metadataFields["scan_target"].GetStringValue())
+}
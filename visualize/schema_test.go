@@ -0,0 +1,34 @@
+package visualize
+
+import (
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type fakeSchema map[string][]string
+
+func (s fakeSchema) DescribeScanTarget(scanType, target string) []string {
+	return s[scanType+" "+target]
+}
+
+func TestTreeNodeSchemaOutput(t *testing.T) {
+	t.Parallel()
+
+	metadata, _ := structpb.NewStruct(map[string]any{"scan_type": "TableScan", "scan_target": "Albums"})
+	node := &TreeNode{planNode: &sppb.PlanNode{Index: 3, DisplayName: "Scan", Metadata: metadata}}
+	param := BuildOptions{Schema: fakeSchema{"TableScan Albums": {"Primary key: SingerId, AlbumId", "Interleaved in: Singers"}}}
+
+	if got, want := node.HTML(param, nil), `<b>Table Scan</b><br align="CENTER"/>Table: Albums<br align="left" />Primary key: SingerId, AlbumId<br align="left" />Interleaved in: Singers<br align="left" />`; got != want {
+		t.Errorf("HTML() = %q, want %q", got, want)
+	}
+	if got, want := node.MermaidLabel(param, nil), "<b>Table&nbsp;Scan</b>\nTable:&nbsp;Albums\nPrimary&nbsp;key:&nbsp;SingerId,&nbsp;AlbumId\nInterleaved&nbsp;in:&nbsp;Singers"; got != want {
+		t.Errorf("MermaidLabel() = %q, want %q", got, want)
+	}
+
+	param.HideScanTarget = true
+	if got := node.GetSchemaOutput(param); got != nil {
+		t.Errorf("GetSchemaOutput() with HideScanTarget = %q, want nil", got)
+	}
+}
@@ -0,0 +1,82 @@
+package visualize
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// selfTimeStatKeys are the cumulative time stats for which self time is computed.
+var selfTimeStatKeys = []string{"latency", "cpu_time"}
+
+// SelfTime returns the exclusive time of the time stat key ("latency" or "cpu_time"),
+// which Spanner reports cumulatively over the subtree of the operator.
+//
+// Child totals are converted to a common unit and subtracted from the operator's total.
+// For latency, remote children, linked with a dashed edge, run in parallel on other servers,
+// so only the largest of them is subtracted; CPU time is additive and all children are summed.
+// A child without the stat, such as Filter Scan, is represented by its nearest descendants
+// that report it. The result is never negative. It returns false if the operator does not
+// report the stat.
+func (n *TreeNode) SelfTime(key string) (time.Duration, bool) {
+	total, ok := statDuration(n.planNode, key)
+	if !ok {
+		return 0, false
+	}
+
+	var local, remote time.Duration
+	for _, link := range n.PlanChildren() {
+		d := cumulativeTime(link.ChildNode, key)
+		if key == "latency" && link.Style == EdgeStyleDashed {
+			remote = max(remote, d)
+		} else {
+			local += d
+		}
+	}
+	return max(total-local-remote, 0), true
+}
+
+// cumulativeTime returns the time stat of node, or the sum over its nearest descendants
+// that report it.
+func cumulativeTime(node *TreeNode, key string) time.Duration {
+	if d, ok := statDuration(node.statsNode(), key); ok {
+		return d
+	}
+	var sum time.Duration
+	for _, link := range node.PlanChildren() {
+		sum += cumulativeTime(link.ChildNode, key)
+	}
+	return sum
+}
+
+// selfTimeStats returns "<key> (self)" stat lines formatted in durationUnit,
+// or in the unit of the operator's own stat if durationUnit is empty.
+func (n *TreeNode) selfTimeStats(durationUnit string) map[string]string {
+	result := make(map[string]string)
+	for _, key := range selfTimeStatKeys {
+		self, ok := n.SelfTime(key)
+		if !ok {
+			continue
+		}
+		if _, ok := durationUnits[durationUnit]; ok {
+			result[key+" (self)"] = formatStatNumber(float64(self)/float64(durationUnits[durationUnit])) + " " + durationUnit
+			continue
+		}
+		unitName := n.planNode.GetExecutionStats().GetFields()[key].GetStructValue().GetFields()["unit"].GetStringValue()
+		result[key+" (self)"] = formatDuration(self, unitName)
+	}
+	return result
+}
+
+// formatDuration formats d like Spanner stats with two decimal places in unitName,
+// switching to a smaller unit when d is less than one unitName.
+func formatDuration(d time.Duration, unitName string) string {
+	i := max(slices.Index(durationUnitOrder, unitName), 0)
+	for ; i < len(durationUnitOrder)-1; i++ {
+		if d == 0 || math.Round(float64(d)/float64(durationUnits[durationUnitOrder[i]])*100) >= 100 {
+			break
+		}
+	}
+	unitName = durationUnitOrder[i]
+	return formatStatNumber(float64(d)/float64(durationUnits[unitName])) + " " + unitName
+}
@@ -0,0 +1,79 @@
+package visualize
+
+import (
+	"testing"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestTreeNodeSelfTime(t *testing.T) {
+	t.Parallel()
+
+	remoteMetadata, _ := structpb.NewStruct(map[string]any{"subquery_cluster_node": "2"})
+	plan, err := BuildPlan(nil, &sppb.ResultSetStats{QueryPlan: &sppb.QueryPlan{PlanNodes: []*sppb.PlanNode{
+		{
+			Index: 0, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Distributed Union",
+			Metadata:       remoteMetadata,
+			ChildLinks:     []*sppb.PlanNode_ChildLink{{ChildIndex: 1}, {ChildIndex: 2}, {ChildIndex: 3}},
+			ExecutionStats: durationStats(t, "msecs", "100", "100"),
+		},
+		// Local child without stats: its descendant is used instead.
+		{Index: 1, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Filter Scan", ChildLinks: []*sppb.PlanNode_ChildLink{{ChildIndex: 4}}},
+		{Index: 2, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Scan", ExecutionStats: durationStats(t, "msecs", "60", "60")},
+		{Index: 3, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Scan", ExecutionStats: durationStats(t, "msecs", "", "5")},
+		{Index: 4, Kind: sppb.PlanNode_RELATIONAL, DisplayName: "Scan", ExecutionStats: durationStats(t, "msecs", "30", "30")},
+	}}}, BuildOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+
+	// latency: 100 - 30 (local) - 60 (remote, parallel)
+	if got, ok := plan.Root.SelfTime("latency"); !ok || got != 10*time.Millisecond {
+		t.Errorf("SelfTime(latency) = %v, %v; want 10ms", got, ok)
+	}
+	// cpu_time is additive: 100 - 30 - 60 - 5
+	if got, ok := plan.Root.SelfTime("cpu_time"); !ok || got != 5*time.Millisecond {
+		t.Errorf("SelfTime(cpu_time) = %v, %v; want 5ms", got, ok)
+	}
+	if _, ok := plan.Root.Children[0].ChildNode.SelfTime("latency"); ok {
+		t.Error("SelfTime() of a node without stats ok = true")
+	}
+
+	got := plan.Root.GetStats(BuildOptions{ExecutionStats: true, SelfTime: true})
+	want := map[string]string{
+		"latency":         "100 msecs",
+		"cpu_time":        "100 msecs",
+		"latency (self)":  "10 msecs",
+		"cpu_time (self)": "5 msecs",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetStats() mismatch (-want +got):\n%s", diff)
+	}
+	if _, ok := plan.Root.GetStats(BuildOptions{ExecutionStats: true})["latency (self)"]; ok {
+		t.Error("GetStats() without SelfTime contains self time")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		d    time.Duration
+		unit string
+		want string
+	}{
+		{1234 * time.Millisecond, "secs", "1.23 secs"},
+		{2850 * time.Microsecond, "secs", "2.85 msecs"},
+		{0, "secs", "0 secs"},
+		{3 * time.Microsecond, "msecs", "3 usecs"},
+		{78030 * time.Microsecond, "msecs", "78.03 msecs"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.d, tt.unit); got != tt.want {
+			t.Errorf("formatDuration(%v, %q) = %q, want %q", tt.d, tt.unit, got, tt.want)
+		}
+	}
+}
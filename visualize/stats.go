@@ -0,0 +1,211 @@
+package visualize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// StatKind classifies an execution stat by the quantity it measures.
+type StatKind string
+
+const (
+	StatKindDuration StatKind = "duration"
+	StatKindBytes    StatKind = "bytes"
+	StatKindRows     StatKind = "rows"
+	StatKindCount    StatKind = "count"
+)
+
+// StatValue is a parsed execution stat such as latency or rows.
+// Total, Mean and StdDeviation are in the canonical unit of Kind:
+// seconds for durations, bytes for sizes, and plain numbers for rows and counts.
+type StatValue struct {
+	Key  string
+	Kind StatKind
+	// Unit is the unit reported by Spanner, e.g. "msecs" or "rows". It may be empty.
+	Unit string
+
+	Total        float64
+	Mean         float64
+	StdDeviation float64
+	HasMean      bool
+	HasStdDev    bool
+}
+
+// Duration returns Total as a duration. It is only meaningful for StatKindDuration.
+func (v StatValue) Duration() time.Duration {
+	return time.Duration(v.Total * float64(time.Second))
+}
+
+// durationUnits maps the units Spanner uses for time stats to durations.
+var durationUnits = map[string]time.Duration{
+	"usecs": time.Microsecond,
+	"msecs": time.Millisecond,
+	"secs":  time.Second,
+	"mins":  time.Minute,
+}
+
+// durationUnitOrder lists the units of durationUnits from the largest.
+var durationUnitOrder = []string{"mins", "secs", "msecs", "usecs"}
+
+// byteUnits maps size units, either reported as the unit or in the stat key, to bytes.
+var byteUnits = map[string]float64{
+	"bytes":  1,
+	"KBytes": 1 << 10,
+	"MBytes": 1 << 20,
+	"GBytes": 1 << 30,
+}
+
+// ParseStatValue parses a raw execution stat, a struct with total, unit and optionally
+// mean and std_deviation, as found in PlanNode.execution_stats.
+func ParseStatValue(key string, v *structpb.Value) (StatValue, error) {
+	fields := v.GetStructValue().GetFields()
+	if fields == nil {
+		return StatValue{}, fmt.Errorf("stat %q is not a struct", key)
+	}
+
+	unit := fields["unit"].GetStringValue()
+	kind, scale := classifyStat(key, unit)
+
+	parse := func(name string) (float64, bool, error) {
+		s := fields[name].GetStringValue()
+		if s == "" {
+			return 0, false, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("stat %q: invalid %s %q", key, name, s)
+		}
+		return f * scale, true, nil
+	}
+
+	total, ok, err := parse("total")
+	if err != nil {
+		return StatValue{}, err
+	}
+	if !ok {
+		return StatValue{}, fmt.Errorf("stat %q has no total", key)
+	}
+	mean, hasMean, err := parse("mean")
+	if err != nil {
+		return StatValue{}, err
+	}
+	stdDev, hasStdDev, err := parse("std_deviation")
+	if err != nil {
+		return StatValue{}, err
+	}
+
+	return StatValue{
+		Key:          key,
+		Kind:         kind,
+		Unit:         unit,
+		Total:        total,
+		Mean:         mean,
+		StdDeviation: stdDev,
+		HasMean:      hasMean,
+		HasStdDev:    hasStdDev,
+	}, nil
+}
+
+// classifyStat returns the kind of a stat and the factor converting its values to the canonical unit.
+func classifyStat(key, unit string) (StatKind, float64) {
+	if d, ok := durationUnits[unit]; ok {
+		return StatKindDuration, d.Seconds()
+	}
+	if b, ok := byteUnits[unit]; ok {
+		return StatKindBytes, b
+	}
+	for name, b := range byteUnits {
+		if strings.HasSuffix(key, "("+name+")") {
+			return StatKindBytes, b
+		}
+	}
+	if unit == "rows" || strings.HasSuffix(key, "rows") || strings.HasPrefix(key, "Rows") {
+		return StatKindRows, 1
+	}
+	return StatKindCount, 1
+}
+
+// ExecutionStats returns the numeric execution stats of the node keyed by their raw name,
+// including stats not known to this package. Stats that cannot be parsed are omitted.
+func (n *TreeNode) ExecutionStats() map[string]StatValue {
+	return parseExecutionStats(n.planNode)
+}
+
+// ExecutionStat returns the parsed execution stat key of the node, e.g. "scanned_rows".
+// It reports false if the node has no such stat or it cannot be parsed.
+func (n *TreeNode) ExecutionStat(key string) (StatValue, bool) {
+	v, ok := n.planNode.GetExecutionStats().GetFields()[key]
+	if !ok || key == "execution_summary" {
+		return StatValue{}, false
+	}
+	sv, err := ParseStatValue(key, v)
+	return sv, err == nil
+}
+
+func parseExecutionStats(node *sppb.PlanNode) map[string]StatValue {
+	result := make(map[string]StatValue)
+	for key, v := range node.GetExecutionStats().GetFields() {
+		if key == "execution_summary" {
+			continue
+		}
+		if sv, err := ParseStatValue(key, v); err == nil {
+			result[key] = sv
+		}
+	}
+	return result
+}
+
+// Format formats v like the raw stat ("total@mean±std_deviation unit").
+// Durations are shown in durationUnit ("usecs", "msecs", "secs" or "mins") if it is not empty,
+// and in the reported unit otherwise. Other kinds are shown in their reported unit.
+func (v StatValue) Format(durationUnit string) string {
+	unit := v.Unit
+	_, scale := classifyStat(v.Key, v.Unit)
+	if d, ok := durationUnits[durationUnit]; ok && v.Kind == StatKindDuration {
+		unit, scale = durationUnit, d.Seconds()
+	}
+
+	s := formatStatNumber(v.Total / scale)
+	if v.HasMean {
+		s += "@" + formatStatNumber(v.Mean/scale)
+		if v.HasStdDev {
+			s += "±" + formatStatNumber(v.StdDeviation/scale)
+		}
+	}
+	if unit != "" {
+		s += " " + unit
+	}
+	return s
+}
+
+// formatStatNumber formats f with at most two decimal places, as Spanner does.
+func formatStatNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// statDuration returns the total of the time stat key of node, such as latency or cpu_time.
+func statDuration(node *sppb.PlanNode, key string) (time.Duration, bool) {
+	v, err := ParseStatValue(key, node.GetExecutionStats().GetFields()[key])
+	if err != nil || v.Kind != StatKindDuration {
+		return 0, false
+	}
+	return v.Duration(), true
+}
+
+// formatDurationStats reformats the duration stats in statsMap in durationUnit.
+func formatDurationStats(node *sppb.PlanNode, statsMap map[string]string, durationUnit string) {
+	if _, ok := durationUnits[durationUnit]; !ok {
+		return
+	}
+	for key, v := range parseExecutionStats(node) {
+		if _, ok := statsMap[key]; ok && v.Kind == StatKindDuration {
+			statsMap[key] = v.Format(durationUnit)
+		}
+	}
+}
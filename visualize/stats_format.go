@@ -3,6 +3,7 @@ package visualize
 import (
 	"bytes"
 	"fmt"
+	"maps"
 	"sort"
 	"strings"
 
@@ -111,9 +112,13 @@ func formatExecutionStatsValue(v stats.ExecutionStatsValue) string {
 	return fmt.Sprintf("%s%s%s", v.Total, meanStr, unitStr)
 }
 
-func formatExecutionSummary(node *sppb.PlanNode, summary stats.ExecutionStatsSummary) string {
+// formatExecutionSummary formats the execution summary lines whose keys pass visible.
+func formatExecutionSummary(node *sppb.PlanNode, summary stats.ExecutionStatsSummary, visible func(key string) bool) string {
 	lines := typedExecutionSummaryLines(summary)
 	mergeUnknownExecutionSummaryLines(node, lines)
+	maps.DeleteFunc(lines, func(key, _ string) bool {
+		return !visible(key)
+	})
 	return renderExecutionSummaryLines(lines)
 }
 
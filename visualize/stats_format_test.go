@@ -138,7 +138,7 @@ func TestFormatExecutionSummary(t *testing.T) {
 		ExecutionStartTimestamp: "1678881600.123456",
 		ExecutionEndTimestamp:   "1678881600.654321",
 		NumCheckPoints:          "19",
-	})
+	}, func(string) bool { return true })
 	want := "execution_summary:\n" +
 		"   checkpoint_time: 0.28 msecs\n" +
 		"   custom_metric: 42\n" +
@@ -298,7 +298,7 @@ func TestGetNodeContent_optionGating(t *testing.T) {
 	}
 
 	t.Run("all disabled", func(t *testing.T) {
-		content := node.getNodeContent(BuildOptions{}, rowType)
+		content := node.Label(BuildOptions{}, rowType)
 		if len(content.Metadata) != 0 {
 			t.Errorf("Metadata = %v, want empty", content.Metadata)
 		}
@@ -314,13 +314,13 @@ func TestGetNodeContent_optionGating(t *testing.T) {
 		if len(content.Stats) != 0 {
 			t.Errorf("Stats = %v, want empty", content.Stats)
 		}
-		if content.ExecutionSummary != "" {
+		if len(content.ExecutionSummary) != 0 {
 			t.Errorf("ExecutionSummary = %q, want empty", content.ExecutionSummary)
 		}
 	})
 
 	t.Run("all enabled", func(t *testing.T) {
-		content := node.getNodeContent(BuildOptions{
+		content := node.Label(BuildOptions{
 			Metadata:          true,
 			SerializeResult:   true,
 			NonVariableScalar: true,
@@ -340,7 +340,7 @@ func TestGetNodeContent_optionGating(t *testing.T) {
 		if len(content.Stats) == 0 {
 			t.Error("Stats should not be empty when enabled")
 		}
-		if content.ExecutionSummary == "" {
+		if len(content.ExecutionSummary) == 0 {
 			t.Error("ExecutionSummary should not be empty when enabled")
 		}
 	})
@@ -0,0 +1,137 @@
+package visualize
+
+import (
+	"testing"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestParseStatValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key    string
+		fields map[string]any
+		want   StatValue
+	}{
+		{
+			key:    "latency",
+			fields: map[string]any{"total": "1.5", "unit": "secs"},
+			want:   StatValue{Key: "latency", Kind: StatKindDuration, Unit: "secs", Total: 1.5},
+		},
+		{
+			key:    "cpu_time",
+			fields: map[string]any{"total": "250", "mean": "50", "std_deviation": "10", "unit": "msecs"},
+			want:   StatValue{Key: "cpu_time", Kind: StatKindDuration, Unit: "msecs", Total: 0.25, Mean: 0.05, StdDeviation: 0.01, HasMean: true, HasStdDev: true},
+		},
+		{
+			key:    "Disk Usage (KBytes)",
+			fields: map[string]any{"total": "2"},
+			want:   StatValue{Key: "Disk Usage (KBytes)", Kind: StatKindBytes, Total: 2048},
+		},
+		{
+			key:    "scanned_rows",
+			fields: map[string]any{"total": "1024000", "unit": "rows"},
+			want:   StatValue{Key: "scanned_rows", Kind: StatKindRows, Unit: "rows", Total: 1024000},
+		},
+		{
+			key:    "Rows Spooled",
+			fields: map[string]any{"total": "7"},
+			want:   StatValue{Key: "Rows Spooled", Kind: StatKindRows, Total: 7},
+		},
+		{
+			key:    "remote_calls",
+			fields: map[string]any{"total": "3", "unit": "calls"},
+			want:   StatValue{Key: "remote_calls", Kind: StatKindCount, Unit: "calls", Total: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseStatValue(tt.key, structpb.NewStructValue(testStruct(t, tt.fields)))
+			if err != nil {
+				t.Fatalf("ParseStatValue() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got, cmpFloat); diff != "" {
+				t.Errorf("ParseStatValue() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	for name, v := range map[string]*structpb.Value{
+		"not a struct":  structpb.NewStringValue("1"),
+		"no total":      structpb.NewStructValue(testStruct(t, map[string]any{"unit": "rows"})),
+		"invalid total": structpb.NewStructValue(testStruct(t, map[string]any{"total": "many"})),
+	} {
+		if _, err := ParseStatValue("rows", v); err == nil {
+			t.Errorf("ParseStatValue(%s) error = nil", name)
+		}
+	}
+}
+
+var cmpFloat = cmp.Comparer(func(a, b float64) bool {
+	const epsilon = 1e-9
+	return a-b < epsilon && b-a < epsilon
+})
+
+func TestStatValueFormat(t *testing.T) {
+	t.Parallel()
+
+	latency, _ := ParseStatValue("latency", structpb.NewStructValue(testStruct(t, map[string]any{"total": "1.08", "mean": "0.54", "std_deviation": "0.1", "unit": "secs"})))
+	rows, _ := ParseStatValue("rows", structpb.NewStructValue(testStruct(t, map[string]any{"total": "3069", "unit": "rows"})))
+	disk, _ := ParseStatValue("Disk Usage (KBytes)", structpb.NewStructValue(testStruct(t, map[string]any{"total": "12"})))
+
+	tests := []struct {
+		value StatValue
+		unit  string
+		want  string
+	}{
+		{latency, "", "1.08@0.54±0.1 secs"},
+		{latency, "msecs", "1080@540±100 msecs"},
+		{latency, "bogus", "1.08@0.54±0.1 secs"},
+		{rows, "msecs", "3069 rows"},
+		{disk, "", "12"},
+	}
+	for _, tt := range tests {
+		if got := tt.value.Format(tt.unit); got != tt.want {
+			t.Errorf("%s.Format(%q) = %q, want %q", tt.value.Key, tt.unit, got, tt.want)
+		}
+	}
+
+	if got := latency.Duration(); got != 1080*time.Millisecond {
+		t.Errorf("Duration() = %v, want 1.08s", got)
+	}
+}
+
+func TestTreeNodeExecutionStats(t *testing.T) {
+	t.Parallel()
+
+	es := testStruct(t, map[string]any{
+		"latency":           map[string]any{"total": "78.03", "unit": "msecs"},
+		"custom_stat":       map[string]any{"total": "5"},
+		"broken":            map[string]any{"total": "n/a"},
+		"execution_summary": map[string]any{"num_executions": "1"},
+	})
+	node := &TreeNode{planNode: &sppb.PlanNode{ExecutionStats: es}}
+
+	got := node.ExecutionStats()
+	if len(got) != 2 {
+		t.Fatalf("ExecutionStats() = %v, want latency and custom_stat", got)
+	}
+	if d := got["latency"].Duration(); d != 78030*time.Microsecond {
+		t.Errorf("latency = %v, want 78.03ms", d)
+	}
+	if got["custom_stat"].Kind != StatKindCount {
+		t.Errorf("custom_stat kind = %q, want count", got["custom_stat"].Kind)
+	}
+
+	stats := node.GetStats(BuildOptions{ExecutionStats: true, DurationUnit: "usecs"})
+	if stats["latency"] != "78030 usecs" {
+		t.Errorf(`GetStats()["latency"] = %q, want "78030 usecs"`, stats["latency"])
+	}
+}
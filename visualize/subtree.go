@@ -0,0 +1,81 @@
+package visualize
+
+import (
+	"fmt"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan"
+)
+
+// subtreeRoot returns the plan node that BuildOptions.RootNode selects.
+func subtreeRoot(qp *spannerplan.QueryPlan, index int32) (*sppb.PlanNode, error) {
+	if index < 0 || int(index) >= len(qp.PlanNodes()) {
+		return nil, fmt.Errorf("root node %d does not exist: plan has %d nodes", index, len(qp.PlanNodes()))
+	}
+	node := qp.GetNodeByIndex(index)
+	if node.GetKind() != sppb.PlanNode_RELATIONAL {
+		return nil, fmt.Errorf("root node %d (%s) is not a relational operator", index, node.GetDisplayName())
+	}
+	return node, nil
+}
+
+// cutAtDepth replaces the operators more than maxDepth-1 levels below node with a placeholder
+// per cut-off child. BuildPlan calls it after the other transformations, so that placeholders
+// stand for the operators that would have been drawn. 0 means no limit.
+func cutAtDepth(node *TreeNode, maxDepth int) {
+	if maxDepth <= 0 {
+		return
+	}
+	for _, link := range node.Children {
+		if maxDepth == 1 {
+			link.ChildNode = newPlaceholder(link.ChildNode)
+		} else {
+			cutAtDepth(link.ChildNode, maxDepth-1)
+		}
+	}
+}
+
+// newPlaceholder returns a node that stands for the operator tree rooted at node.
+// The execution stats of node, which are cumulative over the omitted operators, are kept
+// in omittedStats, so that the placeholder shows them but is not reported as an operator
+// with stats by ExecutionStats, Expr or the advisor.
+func newPlaceholder(node *TreeNode) *TreeNode {
+	omitted := countOperators(node)
+	unit := "operators"
+	if omitted == 1 {
+		unit = "operator"
+	}
+	return &TreeNode{
+		planNode: &sppb.PlanNode{
+			Index:       node.planNode.GetIndex(),
+			Kind:        sppb.PlanNode_RELATIONAL,
+			DisplayName: fmt.Sprintf("… %d more %s", omitted, unit),
+		},
+		queryTotals:  node.queryTotals,
+		omitted:      omitted,
+		omittedStats: node.planNode.GetExecutionStats(),
+	}
+}
+
+func countOperators(node *TreeNode) int {
+	count := 1
+	for _, link := range node.Children {
+		count += countOperators(link.ChildNode)
+	}
+	return count
+}
+
+// statsNode returns the plan node whose execution stats are shown for n: the cumulative stats
+// of the omitted operators for a placeholder, and the operator's own stats otherwise.
+func (n *TreeNode) statsNode() *sppb.PlanNode {
+	if n.omitted > 0 {
+		return &sppb.PlanNode{ExecutionStats: n.omittedStats}
+	}
+	return n.planNode
+}
+
+// OmittedOperators returns the number of operators a placeholder node stands for,
+// or 0 if the node is a real operator.
+func (n *TreeNode) OmittedOperators() int {
+	return n.omitted
+}
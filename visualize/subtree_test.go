@@ -0,0 +1,99 @@
+package visualize
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildPlanSubtree(t *testing.T) {
+	t.Parallel()
+
+	t.Run("root node", func(t *testing.T) {
+		plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{RootNode: 27})
+		if err != nil {
+			t.Fatalf("BuildPlan() error = %v", err)
+		}
+		want := []string{"node27 -[]-> node28", "node28 -[]-> node29"}
+		if diff := cmp.Diff(want, linkLines(plan.Root, plan.Root.Children)); diff != "" {
+			t.Errorf("tree mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("max depth", func(t *testing.T) {
+		plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{MaxDepth: 2})
+		if err != nil {
+			t.Fatalf("BuildPlan() error = %v", err)
+		}
+		want := []string{
+			"node0 -[Input]-> node1",
+			"node1 -[]-> node2_omitted(… 4 more operators)",
+			"node0 -[Map].-> node18",
+			"node18 -[]-> node19_omitted(… 6 more operators)",
+		}
+		if diff := cmp.Diff(want, linkLines(plan.Root, plan.Root.Children)); diff != "" {
+			t.Errorf("tree mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("root node and max depth", func(t *testing.T) {
+		plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{RootNode: 27, MaxDepth: 1})
+		if err != nil {
+			t.Fatalf("BuildPlan() error = %v", err)
+		}
+		want := []string{"node27 -[]-> node28_omitted(… 2 more operators)"}
+		if diff := cmp.Diff(want, linkLines(plan.Root, plan.Root.Children)); diff != "" {
+			t.Errorf("tree mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("only and hidden operators before max depth", func(t *testing.T) {
+		plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{
+			Only:          "index == 29",
+			HideOperators: []string{"Serialize Result"},
+			MaxDepth:      2,
+		})
+		if err != nil {
+			t.Fatalf("BuildPlan() error = %v", err)
+		}
+		want := []string{"node0 -[Map].-> node19", "node19 -[Map]-> node27_omitted(… 3 more operators)"}
+		if diff := cmp.Diff(want, linkLines(plan.Root, plan.Root.Children)); diff != "" {
+			t.Errorf("tree mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("placeholder stats", func(t *testing.T) {
+		plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{
+			ExecutionStats: true,
+			MaxDepth:       1,
+			Highlight:      "latency > 0",
+		})
+		if err != nil {
+			t.Fatalf("BuildPlan() error = %v", err)
+		}
+		placeholder := plan.Root.Children[1].ChildNode
+		if placeholder.OmittedOperators() == 0 {
+			t.Fatalf("%s is not a placeholder", placeholder.GetName())
+		}
+		if _, ok := placeholder.GetStats(plan.Build)["latency"]; !ok {
+			t.Errorf("GetStats() of placeholder has no cumulative latency")
+		}
+		if stats := placeholder.ExecutionStats(); len(stats) > 0 {
+			t.Errorf("ExecutionStats() of placeholder = %v, want none", stats)
+		}
+		if placeholder.IsHighlighted() {
+			t.Errorf("placeholder is highlighted")
+		}
+		if plan.Node(placeholder.PlanNode().GetIndex()) != nil {
+			t.Errorf("Node(%d) returned the placeholder", placeholder.PlanNode().GetIndex())
+		}
+	})
+
+	for name, index := range map[string]int32{"out of range": 1000, "scalar": 6} {
+		t.Run("invalid root node "+name, func(t *testing.T) {
+			if _, err := buildTestPlan(t, "dca_profile.json", BuildOptions{RootNode: index}); err == nil {
+				t.Errorf("BuildPlan(RootNode: %d) error = nil", index)
+			}
+		})
+	}
+}
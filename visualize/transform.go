@@ -0,0 +1,138 @@
+package visualize
+
+import (
+	"fmt"
+	"path"
+)
+
+// validateOperatorPatterns reports the first malformed BuildOptions.HideOperators pattern.
+func validateOperatorPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid operator pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesOperator reports whether the display name or the title of node matches one of patterns.
+func (n *TreeNode) matchesOperator(patterns []string) bool {
+	for _, pattern := range patterns {
+		for _, name := range []string{n.planNode.GetDisplayName(), n.GetTitle()} {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hideOperators removes the operators matching patterns from the tree rooted at node
+// and returns the new root. The children of a hidden operator are linked to its nearest
+// visible ancestor with the child type of the link into the hidden operator, which
+// describes their role for that ancestor; the link is dashed if either link was remote.
+// The root is hidden only if it has a single child, which becomes the new root.
+func hideOperators(root *TreeNode, patterns []string) *TreeNode {
+	if len(patterns) == 0 {
+		return root
+	}
+
+	hideDescendants(root, patterns)
+	if root.matchesOperator(patterns) && len(root.Children) == 1 {
+		return root.Children[0].ChildNode
+	}
+	return root
+}
+
+func hideDescendants(node *TreeNode, patterns []string) {
+	var children []*Link
+	for _, link := range node.Children {
+		child := link.ChildNode
+		hideDescendants(child, patterns)
+		if !child.matchesOperator(patterns) {
+			children = append(children, link)
+			continue
+		}
+		for _, grandchild := range child.Children {
+			style := link.Style
+			if grandchild.Style == EdgeStyleDashed {
+				style = EdgeStyleDashed
+			}
+			children = append(children, &Link{
+				ChildType: link.ChildType,
+				Style:     style,
+				ChildNode: grandchild.ChildNode,
+			})
+		}
+	}
+	node.Children = children
+}
+
+// keepMatching removes the subtrees of node that contain no operator matching expr
+// and reports whether anything below or at node matches. Operators that do not match
+// are kept if a descendant matches, so that the path from the root to every match stays visible.
+func keepMatching(node *TreeNode, expr *Expr) bool {
+	var children []*Link
+	for _, link := range node.Children {
+		if keepMatching(link.ChildNode, expr) {
+			children = append(children, link)
+		}
+	}
+	node.Children = children
+	return len(children) > 0 || expr.Match(node)
+}
+
+// compactChains merges every chain of operators with a single local child into the box of
+// its first operator. The merged operators are listed in Merged, and the box takes over the
+// children of the last one. Remote links and placeholders end a chain, so that the boundaries
+// between servers and the depth limit stay visible.
+func compactChains(node *TreeNode) {
+	for last := node; len(last.Children) == 1; {
+		link := last.Children[0]
+		if link.Style != EdgeStyleSolid || link.ChildNode.omitted > 0 {
+			break
+		}
+		if node.planChildren == nil {
+			node.planChildren = node.Children
+		}
+		node.Merged = append(node.Merged, link.ChildNode)
+		last = link.ChildNode
+		node.Children = last.Children
+	}
+
+	for _, link := range node.Children {
+		compactChains(link.ChildNode)
+	}
+}
+
+// PlanChildren returns the links to the operator's own children in the plan.
+// They differ from Children only for a node that merged a chain of operators,
+// whose Children are the children of the last merged operator.
+func (n *TreeNode) PlanChildren() []*Link {
+	if n.planChildren != nil {
+		return n.planChildren
+	}
+	return n.Children
+}
+
+// mergedLinkType returns the child type of the link into the i-th merged operator.
+func (n *TreeNode) mergedLinkType(i int) string {
+	parent := n
+	if i > 0 {
+		parent = n.Merged[i-1]
+	}
+	for _, link := range parent.PlanChildren() {
+		if link.ChildNode == n.Merged[i] {
+			return link.ChildType
+		}
+	}
+	return ""
+}
+
+// mergedSeparator returns the line drawn between merged operators in a compacted box.
+func mergedSeparator(childType string) string {
+	if childType == "" {
+		return "↓"
+	}
+	return "↓ " + childType
+}
@@ -0,0 +1,77 @@
+package visualize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildPlanHideOperators(t *testing.T) {
+	t.Parallel()
+
+	plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{HideOperators: []string{"Serialize Result", "Create Batch", "*Distributed Union"}})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+	want := []string{
+		"node0 -[Input]-> node2",
+		"node2 -[].-> node5",
+		"node0 -[Map].-> node19",
+		"node19 -[Input]-> node20",
+		"node20 -[]-> node21",
+		"node19 -[Map]-> node28",
+		"node28 -[]-> node29",
+	}
+	if diff := cmp.Diff(want, linkLines(plan.Root, plan.Root.Children)); diff != "" {
+		t.Errorf("links mismatch (-want +got):\n%s", diff)
+	}
+
+	t.Run("root with a single child", func(t *testing.T) {
+		plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{RootNode: 18, HideOperators: []string{"Serialize Result"}})
+		if err != nil {
+			t.Fatalf("BuildPlan() error = %v", err)
+		}
+		if got := plan.Root.GetName(); got != "node19" {
+			t.Errorf("root = %s, want node19", got)
+		}
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		if _, err := buildTestPlan(t, "dca_profile.json", BuildOptions{HideOperators: []string{"["}}); err == nil {
+			t.Error("BuildPlan() error = nil, want invalid pattern error")
+		}
+	})
+}
+
+func TestBuildPlanCompact(t *testing.T) {
+	t.Parallel()
+
+	plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{Compact: true, ExecutionStats: true, SelfTime: true})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+	want := []string{
+		"node0 -[Input]-> node1+node2+node3",
+		"node1+node2+node3 -[].-> node4+node5",
+		"node0 -[Map].-> node18+node19",
+		"node18+node19 -[Input]-> node20+node21",
+		"node18+node19 -[Map]-> node27+node28+node29",
+	}
+	if diff := cmp.Diff(want, linkLines(plan.Root, plan.Root.Children)); diff != "" {
+		t.Errorf("links mismatch (-want +got):\n%s", diff)
+	}
+
+	// Self time is computed from the operators' own children, not from the box.
+	box := plan.Root.Children[1].ChildNode
+	if got, want := box.GetStats(plan.Build)["latency (self)"], "1.53 msecs"; got != want {
+		t.Errorf("latency (self) of %s = %q, want %q", box.GetName(), got, want)
+	}
+
+	label := box.MermaidLabel(plan.Build, plan.RowType)
+	for _, want := range []string{"<b>Serialize&nbsp;Result</b>", "\n↓\n<b>Cross&nbsp;Apply</b>"} {
+		if !strings.Contains(label, want) {
+			t.Errorf("MermaidLabel() = %q, want it to contain %q", label, want)
+		}
+	}
+}
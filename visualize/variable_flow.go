@@ -0,0 +1,127 @@
+package visualize
+
+import (
+	"slices"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan"
+)
+
+// VariableFlowColor is the color renderers draw variable flows and their labels in.
+const VariableFlowColor = "steelblue"
+
+// VariableFlow is a dataflow edge from the operator that defines variables, such as the
+// batch of a Create Batch or the columns of a Scan, to an operator whose scalar expressions
+// or batch scan reference them.
+type VariableFlow struct {
+	From, To *TreeNode
+	// Variables are the referenced variables with a leading "$", sorted by name.
+	Variables []string
+}
+
+// buildVariableFlows returns the variable flows between the boxes of the tree below root.
+// Operators merged by BuildOptions.Compact are represented by their box, and flows within
+// one box or from operators that are not in the tree are dropped.
+func buildVariableFlows(qp *spannerplan.QueryPlan, root *TreeNode) []VariableFlow {
+	var operators []*TreeNode
+	boxes := make(map[*TreeNode]*TreeNode)
+	walkTree(root, func(node *TreeNode) bool {
+		if node.omitted > 0 {
+			return false
+		}
+		if _, ok := boxes[node]; !ok {
+			boxes[node] = node
+		}
+		for _, merged := range node.Merged {
+			boxes[merged] = node
+		}
+		operators = append(operators, node)
+		return true
+	})
+
+	definers := make(map[string][]*TreeNode)
+	for _, node := range operators {
+		for _, cl := range node.planNode.GetChildLinks() {
+			if name := cl.GetVariable(); name != "" && !slices.Contains(definers[name], node) {
+				definers[name] = append(definers[name], node)
+			}
+		}
+	}
+
+	type edge struct{ from, to *TreeNode }
+	var order []edge
+	variables := make(map[edge][]string)
+	for _, node := range operators {
+		for _, ref := range variableReferences(qp, node.planNode) {
+			for _, definer := range resolveVariable(definers, strings.TrimPrefix(ref, "$")) {
+				e := edge{boxes[definer], boxes[node]}
+				if e.from == e.to || slices.Contains(variables[e], ref) {
+					continue
+				}
+				if _, ok := variables[e]; !ok {
+					order = append(order, e)
+				}
+				variables[e] = append(variables[e], ref)
+			}
+		}
+	}
+
+	var flows []VariableFlow
+	for _, e := range order {
+		vars := variables[e]
+		slices.Sort(vars)
+		flows = append(flows, VariableFlow{From: e.from, To: e.to, Variables: vars})
+	}
+	return flows
+}
+
+// resolveVariable returns the operators defining name. A reference to a struct or a batch,
+// such as $v1 or $v2, is resolved to the operators defining its fields, such as v1.SingerId.
+func resolveVariable(definers map[string][]*TreeNode, name string) []*TreeNode {
+	if nodes, ok := definers[name]; ok {
+		return nodes
+	}
+	var result []*TreeNode
+	for defined, nodes := range definers {
+		if strings.HasPrefix(defined, name+".") {
+			for _, node := range nodes {
+				if !slices.Contains(result, node) {
+					result = append(result, node)
+				}
+			}
+		}
+	}
+	// Map iteration order is random; keep the order of the plan.
+	slices.SortFunc(result, func(a, b *TreeNode) int {
+		return int(a.planNode.GetIndex() - b.planNode.GetIndex())
+	})
+	return result
+}
+
+// variableReferences returns the variables referenced by the scalar expressions of planNode,
+// and by its scan target if it scans a batch, in the order of the plan.
+func variableReferences(qp *spannerplan.QueryPlan, planNode *sppb.PlanNode) []string {
+	var refs []string
+	if target := planNode.GetMetadata().GetFields()["scan_target"].GetStringValue(); strings.HasPrefix(target, "$") {
+		refs = append(refs, target)
+	}
+
+	var walk func(node *sppb.PlanNode)
+	walk = func(node *sppb.PlanNode) {
+		if desc := node.GetShortRepresentation().GetDescription(); node.GetDisplayName() == "Reference" && strings.HasPrefix(desc, "$") {
+			refs = append(refs, desc)
+		}
+		for _, cl := range node.GetChildLinks() {
+			if !qp.IsVisible(cl) {
+				walk(qp.GetNodeByChildLink(cl))
+			}
+		}
+	}
+	for _, cl := range planNode.GetChildLinks() {
+		if !qp.IsVisible(cl) {
+			walk(qp.GetNodeByChildLink(cl))
+		}
+	}
+	return refs
+}
@@ -0,0 +1,52 @@
+package visualize
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildPlanVariableFlows(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc string
+		opts BuildOptions
+		want []string
+	}{
+		{"disabled", BuildOptions{}, nil},
+		{"operators", BuildOptions{VariableFlows: true}, []string{
+			"node29 -[$SingerId_1]~> node0",
+			"node5 -[$SingerId]~> node0",
+			"node2 -[$v1]~> node1",
+			"node5 -[$BirthDate $FirstName $LastName $SingerId $SingerInfo]~> node2",
+			"node21 -[$batched_BirthDate $batched_FirstName $batched_LastName $batched_SingerId $batched_SingerInfo]~> node18",
+			"node29 -[$AlbumId $Duration $SongGenre $SongName $TrackId]~> node18",
+			"node1 -[$v2]~> node21",
+			"node29 -[$SongName]~> node28",
+			"node21 -[$batched_SingerId]~> node29",
+		}},
+		// Flows between operators merged into one box are dropped.
+		{"compact", BuildOptions{VariableFlows: true, Compact: true}, []string{
+			"node27+node28+node29 -[$SingerId_1]~> node0",
+			"node4+node5 -[$SingerId]~> node0",
+			"node4+node5 -[$BirthDate $FirstName $LastName $SingerId $SingerInfo]~> node1+node2+node3",
+			"node20+node21 -[$batched_BirthDate $batched_FirstName $batched_LastName $batched_SingerId $batched_SingerInfo]~> node18+node19",
+			"node27+node28+node29 -[$AlbumId $Duration $SongGenre $SongName $TrackId]~> node18+node19",
+			"node1+node2+node3 -[$v2]~> node20+node21",
+			"node20+node21 -[$batched_SingerId]~> node27+node28+node29",
+		}},
+		// Variables defined outside of the subtree have no flow.
+		{"subtree", BuildOptions{VariableFlows: true, RootNode: 27}, []string{
+			"node29 -[$SongName]~> node28",
+		}},
+	} {
+		plan, err := buildTestPlan(t, "dca_profile.json", tt.opts)
+		if err != nil {
+			t.Fatalf("%s: BuildPlan() error = %v", tt.desc, err)
+		}
+		if diff := cmp.Diff(tt.want, flowLines(plan.VariableFlows)); diff != "" {
+			t.Errorf("%s: VariableFlows mismatch (-want +got):\n%s", tt.desc, diff)
+		}
+	}
+}
@@ -194,12 +194,12 @@ func TestMermaidLabel_Golden(t *testing.T) {
 	}
 }
 
-// formatNodeContentAsText formats node.getNodeContent for test.
+// formatNodeContentAsText formats node.Label for test.
 func formatNodeContentAsText(node *TreeNode, qp *spannerplan.QueryPlan, param BuildOptions, rowType *sppb.StructType) []string {
 	if node == nil {
 		return nil
 	}
-	content := node.getNodeContent(param, rowType)
+	content := node.Label(param, rowType)
 	var result []string
 
 	if content.Title != "" {
@@ -222,8 +222,8 @@ func formatNodeContentAsText(node *TreeNode, qp *spannerplan.QueryPlan, param Bu
 
 	if len(content.Metadata) > 0 {
 		var metaLines []string
-		for k, v := range content.Metadata {
-			metaLines = append(metaLines, fmt.Sprintf("Metadata: %s = %s", k, v))
+		for _, kv := range content.Metadata {
+			metaLines = append(metaLines, fmt.Sprintf("Metadata: %s = %s", kv.Key, kv.Value))
 		}
 		sort.Strings(metaLines) // Ensure deterministic order for golden files
 		result = append(result, metaLines...)
@@ -235,19 +235,15 @@ func formatNodeContentAsText(node *TreeNode, qp *spannerplan.QueryPlan, param Bu
 
 	if len(content.Stats) > 0 {
 		var statLines []string
-		for k, v := range content.Stats {
-			statLines = append(statLines, fmt.Sprintf("Stat: %s: %s", k, v))
+		for _, kv := range content.Stats {
+			statLines = append(statLines, fmt.Sprintf("Stat: %s: %s", kv.Key, kv.Value))
 		}
 		sort.Strings(statLines) // Ensure deterministic order for golden files
 		result = append(result, statLines...)
 	}
 
-	if content.ExecutionSummary != "" {
-		for _, line := range strings.Split(strings.TrimSuffix(content.ExecutionSummary, "\n"), "\n") {
-			if line != "" {
-				result = append(result, fmt.Sprintf("ExecutionSummary: %s", line))
-			}
-		}
+	for _, line := range content.ExecutionSummary {
+		result = append(result, fmt.Sprintf("ExecutionSummary: %s", line))
 	}
 
 	return result
@@ -0,0 +1,68 @@
+package visualize
+
+// Walk visits the operators of the plan in depth-first pre-order, starting at Root.
+// Operators merged by BuildOptions.Compact are visited as children of the operator above them,
+// as in the plan. If fn returns false, the descendants of node are skipped.
+func (p *Plan) Walk(fn func(node *TreeNode) bool) {
+	if p == nil || p.Root == nil {
+		return
+	}
+	walkTree(p.Root, fn)
+}
+
+func walkTree(node *TreeNode, fn func(node *TreeNode) bool) {
+	if !fn(node) {
+		return
+	}
+	for _, link := range node.PlanChildren() {
+		walkTree(link.ChildNode, fn)
+	}
+}
+
+// Find returns the operators for which match returns true, in the order of Walk.
+func (p *Plan) Find(match func(node *TreeNode) bool) []*TreeNode {
+	var result []*TreeNode
+	p.Walk(func(node *TreeNode) bool {
+		if match(node) {
+			result = append(result, node)
+		}
+		return true
+	})
+	return result
+}
+
+// Node returns the operator with the given plan node index, or nil if it is not in the tree,
+// e.g. because it is a scalar operator or it was hidden.
+func (p *Plan) Node(index int32) *TreeNode {
+	var found *TreeNode
+	p.Walk(func(node *TreeNode) bool {
+		if found == nil && node.omitted == 0 && node.planNode.GetIndex() == index {
+			found = node
+		}
+		return found == nil
+	})
+	return found
+}
+
+// linkParents sets the parent and depth of node and its descendants.
+func linkParents(node *TreeNode, parent *TreeNode) {
+	node.parent = parent
+	node.depth = 0
+	if parent != nil {
+		node.depth = parent.depth + 1
+	}
+	for _, link := range node.PlanChildren() {
+		linkParents(link.ChildNode, node)
+	}
+}
+
+// Parent returns the operator above node in the plan, or nil for the root.
+// It is the operator a merged node was merged below, not the box it is drawn in.
+func (n *TreeNode) Parent() *TreeNode {
+	return n.parent
+}
+
+// Depth returns the number of operators between node and the root; the root has depth 0.
+func (n *TreeNode) Depth() int {
+	return n.depth
+}
@@ -0,0 +1,100 @@
+package visualize
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlanWalk(t *testing.T) {
+	t.Parallel()
+
+	plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+
+	type visit struct {
+		Name   string
+		Depth  int
+		Parent string
+	}
+	var got []visit
+	plan.Walk(func(node *TreeNode) bool {
+		v := visit{Name: node.GetName(), Depth: node.Depth()}
+		if parent := node.Parent(); parent != nil {
+			v.Parent = parent.GetName()
+		}
+		got = append(got, v)
+		// Skip the subtree below Create Batch.
+		return node.GetName() != "node1"
+	})
+	want := []visit{
+		{"node0", 0, ""},
+		{"node1", 1, "node0"},
+		{"node18", 1, "node0"},
+		{"node19", 2, "node18"},
+		{"node20", 3, "node19"},
+		{"node21", 4, "node20"},
+		{"node27", 3, "node19"},
+		{"node28", 4, "node27"},
+		{"node29", 5, "node28"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Walk() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPlanFind(t *testing.T) {
+	t.Parallel()
+
+	plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{Compact: true})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+
+	// Merged operators are found, too.
+	scans := plan.Find(func(node *TreeNode) bool {
+		_, ok := node.ExecutionStat("scanned_rows")
+		return ok
+	})
+	if len(scans) == 0 {
+		t.Fatal("Find() found no scans")
+	}
+	var names []string
+	most := scans[0]
+	for _, scan := range scans {
+		names = append(names, scan.GetName())
+		if mustStat(t, scan, "scanned_rows").Total > mustStat(t, most, "scanned_rows").Total {
+			most = scan
+		}
+	}
+	if diff := cmp.Diff([]string{"node5", "node29"}, names); diff != "" {
+		t.Errorf("Find() mismatch (-want +got):\n%s", diff)
+	}
+	if most.GetName() != "node29" || mustStat(t, most, "scanned_rows").Total != 1024000 {
+		t.Errorf("most scanned rows = %s, want node29 with 1024000", most.GetName())
+	}
+	if got := most.Parent().GetName(); got != "node28" {
+		t.Errorf("Parent() of merged node29 = %s, want node28", got)
+	}
+
+	if node := plan.Node(19); node == nil || node.GetTitle() != "Cross Apply" {
+		t.Errorf("Node(19) = %v, want Cross Apply", node)
+	}
+	if node := plan.Node(6); node != nil {
+		t.Errorf("Node(6) = %s, want nil for a scalar operator", node.GetName())
+	}
+	if _, ok := plan.Node(0).ExecutionStat("execution_summary"); ok {
+		t.Error("ExecutionStat(execution_summary) reported a numeric stat")
+	}
+}
+
+func mustStat(t *testing.T, node *TreeNode, key string) StatValue {
+	t.Helper()
+	v, ok := node.ExecutionStat(key)
+	if !ok {
+		t.Fatalf("%s has no %s", node.GetName(), key)
+	}
+	return v
+}
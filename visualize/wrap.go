@@ -0,0 +1,143 @@
+package visualize
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/apstndb/go-tabwrap"
+)
+
+// truncationMark ends the last line kept of a truncated item.
+const truncationMark = "…"
+
+// wrapText breaks s into lines of at most width display columns, so that East Asian
+// wide characters count twice. Lines are broken after spaces and commas where possible,
+// and words wider than width are broken between grapheme clusters. Continuation lines
+// repeat the indentation of s plus two spaces. s is not escaped, so that escaping the
+// lines afterwards never splits an HTML entity. A width of 0 or less disables wrapping.
+func wrapText(s string, width int) []string {
+	if width <= 0 || tabwrap.StringWidth(s) <= width {
+		return []string{s}
+	}
+
+	indent := s[:len(s)-len(strings.TrimLeftFunc(s, unicode.IsSpace))]
+	continuation := indent + "  "
+	if tabwrap.StringWidth(continuation) >= width {
+		continuation = ""
+	}
+
+	var lines []string
+	line := ""
+	flush := func() {
+		lines = append(lines, strings.TrimRightFunc(line, unicode.IsSpace))
+		line = continuation
+	}
+	fits := func(word string) bool {
+		return tabwrap.StringWidth(line+strings.TrimRight(word, " ")) <= width
+	}
+	for _, word := range splitWords(s) {
+		if !fits(word) && strings.TrimSpace(line) != "" {
+			flush()
+			word = strings.TrimLeft(word, " ")
+		}
+		// Break a word that does not fit on a line of its own.
+		for !fits(word) {
+			head := tabwrap.Truncate(word, width-tabwrap.StringWidth(line), "")
+			if head == "" {
+				_, size := utf8.DecodeRuneInString(word)
+				head = word[:size]
+			}
+			line += head
+			word = word[len(head):]
+			flush()
+		}
+		line += word
+	}
+	if strings.TrimSpace(line) != "" {
+		flush()
+	}
+	return lines
+}
+
+// splitWords splits s after every space and comma, keeping the separators.
+func splitWords(s string) []string {
+	var words []string
+	start := 0
+	for i, r := range s {
+		if r == ' ' || r == ',' {
+			words = append(words, s[start:i+1])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// labelWrapper wraps and truncates label items as BuildOptions.WrapWidth and
+// BuildOptions.MaxLines select, and records the full text of truncated items.
+type labelWrapper struct {
+	width     int
+	maxLines  int
+	truncated []string
+}
+
+func newLabelWrapper(param BuildOptions) *labelWrapper {
+	return &labelWrapper{width: param.WrapWidth, maxLines: param.MaxLines}
+}
+
+// wrap returns the display lines of a single item.
+func (w *labelWrapper) wrap(item string) []string {
+	return w.truncate(item, wrapText(item, w.width))
+}
+
+// block wraps every line of the multi-line item s and truncates them together.
+func (w *labelWrapper) block(s string) string {
+	if w.width <= 0 && w.maxLines <= 0 {
+		return s
+	}
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		lines = append(lines, wrapText(line, w.width)...)
+	}
+	return strings.Join(w.truncate(s, lines), "\n")
+}
+
+// truncate keeps the first maxLines of the display lines of item and records item if
+// any were dropped.
+func (w *labelWrapper) truncate(item string, lines []string) []string {
+	if w.maxLines <= 0 || len(lines) <= w.maxLines {
+		return lines
+	}
+
+	w.truncated = append(w.truncated, item)
+	lines = lines[:w.maxLines]
+	last := lines[len(lines)-1]
+	if w.width > 0 && tabwrap.StringWidth(last+truncationMark) > w.width {
+		last = tabwrap.Truncate(last, w.width-tabwrap.StringWidth(truncationMark), "")
+	}
+	lines[len(lines)-1] = last + truncationMark
+	return lines
+}
+
+// lines wraps every item of items.
+func (w *labelWrapper) lines(items []string) []string {
+	if w.width <= 0 && w.maxLines <= 0 {
+		return items
+	}
+	var result []string
+	for _, item := range items {
+		result = append(result, w.wrap(item)...)
+	}
+	return result
+}
+
+// text wraps s and joins its lines with newlines.
+func (w *labelWrapper) text(s string) string {
+	if s == "" || (w.width <= 0 && w.maxLines <= 0) {
+		return s
+	}
+	return strings.Join(w.wrap(s), "\n")
+}
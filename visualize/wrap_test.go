@@ -0,0 +1,110 @@
+package visualize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apstndb/go-tabwrap"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWrapText(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc  string
+		s     string
+		width int
+		want  []string
+	}{
+		{"disabled", "Seek Condition: ($SingerId = $batched_SingerId)", 0, []string{"Seek Condition: ($SingerId = $batched_SingerId)"}},
+		{"fits", "Split Range: true", 20, []string{"Split Range: true"}},
+		{"spaces", "Seek Condition: ($SingerId = $batched_SingerId)", 24,
+			[]string{"Seek Condition:", "  ($SingerId =", "  $batched_SingerId)"}},
+		{"commas", "a,b,c,d", 4, []string{"a,b,", "  c,", "  d"}},
+		{"indented", "  Key: a AND b AND c", 12, []string{"  Key: a AND", "    b AND c"}},
+		{"long word", "$batched_SingerId_1234", 10, []string{"$batched_S", "  ingerId_", "  1234"}},
+		{"wide characters", "名前 = 'アイウエオカキ'", 10, []string{"名前 =", "  'アイウ", "  エオカキ", "  '"}},
+	} {
+		if diff := cmp.Diff(tt.want, wrapText(tt.s, tt.width)); diff != "" {
+			t.Errorf("%s: wrapText(%q, %d) mismatch (-want +got):\n%s", tt.desc, tt.s, tt.width, diff)
+		}
+		if tt.width > 0 {
+			for _, line := range wrapText(tt.s, tt.width) {
+				if w := tabwrap.StringWidth(line); w > tt.width {
+					t.Errorf("%s: line %q is %d columns wide, want at most %d", tt.desc, line, w, tt.width)
+				}
+			}
+		}
+	}
+}
+
+func TestWrapTextKeepsEntities(t *testing.T) {
+	t.Parallel()
+
+	s := `Residual Condition: ($a <> 'x&y' AND $b < "z")`
+	lines := wrapText(s, 8)
+	for _, escape := range []func(string) string{escapeGraphvizHTMLLabelContent, escapeMermaidLabelContent} {
+		var unescaped []string
+		for _, line := range lines {
+			escaped := escape(line)
+			for _, entity := range []string{"&lt;", "&gt;", "&amp;", "&#34;", "&#39;", "&quot;", "&nbsp;"} {
+				escaped = strings.ReplaceAll(escaped, entity, "")
+			}
+			unescaped = append(unescaped, escaped)
+		}
+		for _, line := range unescaped {
+			if strings.ContainsAny(line, "&<>") {
+				t.Errorf("escaped line %q has a broken entity", line)
+			}
+		}
+	}
+}
+
+func TestLabelWrapper(t *testing.T) {
+	t.Parallel()
+
+	w := newLabelWrapper(BuildOptions{WrapWidth: 16, MaxLines: 2})
+	got := w.lines([]string{"Split Range: true", "Seek Condition: ($SingerId = $batched_SingerId)"})
+	want := []string{"Split Range:", "  true", "Seek Condition:", "  ($SingerId =…"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("lines() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"Seek Condition: ($SingerId = $batched_SingerId)"}, w.truncated); diff != "" {
+		t.Errorf("truncated mismatch (-want +got):\n%s", diff)
+	}
+
+	query := "SELECT *\nFROM Singers\nJOIN Songs USING (SingerId)"
+	if got, want := newLabelWrapper(BuildOptions{MaxLines: 2}).block(query), "SELECT *\nFROM Singers…"; got != want {
+		t.Errorf("block() = %q, want %q", got, want)
+	}
+	if got := newLabelWrapper(BuildOptions{}).block(query); got != query {
+		t.Errorf("block() = %q, want it unchanged", got)
+	}
+}
+
+func TestTreeNodeTooltipTruncated(t *testing.T) {
+	t.Parallel()
+
+	plan, err := buildTestPlan(t, "dca_profile.json", BuildOptions{NonVariableScalar: true, WrapWidth: 24, MaxLines: 2})
+	if err != nil {
+		t.Fatalf("BuildPlan() error = %v", err)
+	}
+	node := plan.Node(29)
+	label := node.Label(plan.Build, plan.RowType)
+	if diff := cmp.Diff([]string{"Seek Condition:", "  ($SingerId_1 =…"}, label.NonVarScalarLinks); diff != "" {
+		t.Errorf("NonVarScalarLinks mismatch (-want +got):\n%s", diff)
+	}
+
+	tooltip, err := node.Tooltip(plan.Build, plan.RowType)
+	if err != nil {
+		t.Fatalf("Tooltip() error = %v", err)
+	}
+	if want := "Seek Condition: ($SingerId_1 = $batched_SingerId)\n\n"; !strings.HasPrefix(tooltip, want) {
+		t.Errorf("Tooltip() = %q, want prefix %q", tooltip, want)
+	}
+
+	if tooltip, err := plan.Node(5).Tooltip(plan.Build, plan.RowType); err != nil || strings.Contains(tooltip, "\n\n") {
+		t.Errorf("Tooltip() = %q, %v, want only the plan node", tooltip, err)
+	}
+}